@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"libvpxGo/vpxgo"
+
+	"gocv.io/x/gocv"
+)
+
+// runAlpha は alpha モードの処理です。-src の透過画像をアルファ付き WebM にエンコードするか、
+// -in のアルファ付き WebM を RGBA の PNG 連番に復号します。
+func runAlpha(srcPath, inPath, outPath, outDir, codecName string, kbps, maxFrames int) error {
+	switch {
+	case srcPath != "" && outPath != "":
+		return encodeAlpha(srcPath, outPath, codecName, kbps, maxFrames)
+	case inPath != "" && outDir != "":
+		return decodeAlpha(inPath, outDir, maxFrames)
+	}
+	return fmt.Errorf("-src と -out (エンコード) または -in と -outdir (デコード) を指定してください")
+}
+
+// encodeAlpha は透過画像を少しずつ動かしながら maxFrames フレーム分エンコードします。
+func encodeAlpha(srcPath, outPath, codecName string, kbps, maxFrames int) error {
+	codec, err := vpxgo.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	src := gocv.IMRead(srcPath, gocv.IMReadUnchanged)
+	if src.Empty() {
+		return fmt.Errorf("画像を読み込めません: %s", srcPath)
+	}
+	defer src.Close()
+	if src.Type() == gocv.MatTypeCV8UC3 {
+		if err := gocv.CvtColor(src, &src, gocv.ColorBGRToBGRA); err != nil {
+			return fmt.Errorf("色変換エラー: %v", err)
+		}
+	}
+	// VP8/VP9 の 4:2:0 に合わせて偶数サイズにする
+	w, h := src.Cols()&^1, src.Rows()&^1
+	overlay := src.Region(image.Rect(0, 0, w, h))
+	defer overlay.Close()
+	if maxFrames <= 0 {
+		maxFrames = 60
+	}
+
+	cfg := vpxgo.DefaultEncoderConfig(w, h)
+	cfg.Codec = codec
+	cfg.BitrateKbps = kbps
+	enc, err := vpxgo.NewAlphaEncoder(cfg, 0)
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ww, err := vpxgo.NewAlphaWebMWriter(f, codec, w, h, cfg.FPS)
+	if err != nil {
+		return err
+	}
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+	write := func(packets []vpxgo.AlphaPacket) error {
+		for _, p := range packets {
+			if err := ww.WritePacketAlpha(p.Packet, p.Alpha); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := 0; i < maxFrames; i++ {
+		// 横方向に巡回シフトして動きのあるオーバーレイにする
+		if shift := i * 4 % w; shift == 0 {
+			overlay.CopyTo(&frame)
+		} else {
+			right := overlay.Region(image.Rect(w-shift, 0, w, h))
+			left := overlay.Region(image.Rect(0, 0, w-shift, h))
+			gocv.Hconcat(right, left, &frame)
+			right.Close()
+			left.Close()
+		}
+
+		packets, err := enc.EncodeBGRA(frame, 0)
+		if err != nil {
+			return err
+		}
+		if err := write(packets); err != nil {
+			return err
+		}
+	}
+	packets, err := enc.Flush()
+	if err != nil {
+		return err
+	}
+	if err := write(packets); err != nil {
+		return err
+	}
+	if err := ww.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%d フレームをアルファ付きで %s に書き出しました\n", maxFrames, outPath)
+	return nil
+}
+
+// decodeAlpha はアルファ付き WebM を復号し、各フレームを PNG で保存します。
+func decodeAlpha(inPath, outDir string, maxFrames int) error {
+	r, closer, err := vpxgo.OpenPacketReader(inPath)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	if !r.Info().Alpha {
+		fmt.Println("警告: AlphaMode が指定されていません。不透明として復号します")
+	}
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	dec, err := vpxgo.NewAlphaDecoder(r.Info().Codec)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	n := 0
+	for maxFrames <= 0 || n < maxFrames {
+		pkt, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		images, err := dec.Decode(pkt)
+		if err != nil {
+			return err
+		}
+		for _, img := range images {
+			out, err := os.Create(filepath.Join(outDir, fmt.Sprintf("frame%05d.png", n)))
+			if err != nil {
+				return err
+			}
+			err = png.Encode(out, img)
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return fmt.Errorf("PNG 書き込みエラー: %v", err)
+			}
+			n++
+		}
+	}
+	fmt.Printf("%d フレームを %s に書き出しました\n", n, outDir)
+	return nil
+}
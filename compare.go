@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"libvpxGo/vpxgo"
+)
+
+// runCompare は compare モードの処理です。
+func runCompare(srcPath, encodedPath, csvPath string) error {
+	if srcPath == "" || encodedPath == "" {
+		return fmt.Errorf("-src と -in を指定してください")
+	}
+	results, sum, err := vpxgo.CompareQuality(srcPath, encodedPath)
+	if err != nil {
+		return err
+	}
+
+	if csvPath != "" {
+		f, err := os.Create(csvPath)
+		if err != nil {
+			return err
+		}
+		if err := vpxgo.WriteQualityCSV(f, results); err != nil {
+			f.Close()
+			return fmt.Errorf("CSV 書き込みエラー: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	for _, q := range results {
+		fmt.Printf("frame %5d  PSNR Y/U/V %6.2f/%6.2f/%6.2f  SSIM %.4f\n", q.Frame, q.PSNRY, q.PSNRU, q.PSNRV, q.SSIM)
+	}
+	fmt.Printf("\n比較フレーム数: %d (対応なし: %d)\n", sum.Frames, sum.Missing)
+	fmt.Printf("平均 PSNR  Y/U/V: %.2f / %.2f / %.2f dB, 全体 %.2f dB (global %.2f dB)\n",
+		sum.MeanPSNRY, sum.MeanPSNRU, sum.MeanPSNRV, sum.MeanPSNR, sum.GlobalPSNR)
+	fmt.Printf("PSNR 最小 %.2f dB, 下位5%% %.2f dB\n", sum.MinPSNR, sum.P5PSNR)
+	fmt.Printf("SSIM 平均 %.4f, 最小 %.4f\n", sum.MeanSSIM, sum.MinSSIM)
+	return nil
+}
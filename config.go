@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"libvpxGo/vpxgo"
+)
+
+// resolveConfig はプリセットとプロファイルファイルから最終的なエンコーダー設定を作ります。
+// どちらも指定されなければ従来の既定値 (DefaultEncoderConfig) を使います。
+func resolveConfig(preset, profilePath string, width, height int) (vpxgo.EncoderConfig, error) {
+	cfg := vpxgo.DefaultEncoderConfig(width, height)
+	if preset != "" {
+		var err error
+		if cfg, err = vpxgo.Preset(preset, width, height); err != nil {
+			return cfg, err
+		}
+	}
+	if profilePath != "" {
+		return vpxgo.LoadProfile(profilePath, cfg)
+	}
+	return cfg, cfg.Validate()
+}
+
+// flagConfig はプリセットとプロファイルの設定に、明示的に指定された -codec, -bitrate,
+// -cpu-used を上書きした設定を返します。set は明示的に指定されたフラグ名で、
+// どのモードでも同じようにプリセットより優先する。
+func flagConfig(preset, profilePath string, width, height int, set map[string]bool, codecName string, kbps int, cpuUsed string) (vpxgo.EncoderConfig, error) {
+	cfg, err := resolveConfig(preset, profilePath, width, height)
+	if err != nil {
+		return cfg, err
+	}
+	if set["codec"] {
+		if cfg.Codec, err = vpxgo.ParseCodec(codecName); err != nil {
+			return cfg, err
+		}
+	}
+	if set["bitrate"] {
+		cfg.BitrateKbps = kbps
+	}
+	if set["cpu-used"] {
+		cpu, err := strconv.Atoi(cpuUsed)
+		if err != nil {
+			return cfg, fmt.Errorf("-cpu-used は整数で指定してください: %q", cpuUsed)
+		}
+		cfg.Controls.CpuUsed = &cpu
+	}
+	return cfg, cfg.Validate()
+}
+
+// fileConfig は encode/transcode モードの基本設定を返します。-preset も -profile も
+// なければ nil を返し、従来どおり -codec, -bitrate, -cpu-used から設定を作る。
+// 解像度とフレームレートはソースに合わせて上書きされる。
+func fileConfig(preset, profilePath string, set map[string]bool, codecName string, kbps int, cpuUsed string) (*vpxgo.EncoderConfig, error) {
+	if preset == "" && profilePath == "" {
+		return nil, nil
+	}
+	// 解像度は検証を通すための仮の値
+	cfg, err := flagConfig(preset, profilePath, 640, 480, set, codecName, kbps, cpuUsed)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// runConfig は config モードの処理です。解決済みの設定を JSON で表示します。
+func runConfig(cfg vpxgo.EncoderConfig) error {
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"libvpxGo/vpxgo"
+)
+
+// runEncode は encode モードの処理です。
+func runEncode(srcPath, outPath string, base *vpxgo.EncoderConfig, codecName string, kbps int, cpuUsed string, passes, pass int, statsPath string, maxFrames int, sceneCut bool, sceneThreshold float64, cutsPath string) error {
+	if srcPath == "" || (outPath == "" && pass != 1) {
+		return fmt.Errorf("-src と -out を指定してください")
+	}
+	codec, err := vpxgo.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	cpu, err := strconv.Atoi(cpuUsed)
+	if err != nil {
+		return fmt.Errorf("-cpu-used は整数で指定してください: %q", cpuUsed)
+	}
+	cuts := []vpxgo.SceneCut{}
+	err = vpxgo.EncodeFile(srcPath, outPath, vpxgo.FileEncodeOptions{
+		Codec:          codec,
+		BitrateKbps:    kbps,
+		CpuUsed:        cpu,
+		Config:         base,
+		MaxFrames:      maxFrames,
+		Passes:         passes,
+		Pass:           pass,
+		StatsPath:      statsPath,
+		SceneCut:       sceneCut || cutsPath != "",
+		SceneThreshold: sceneThreshold,
+		OnSceneCut: func(c vpxgo.SceneCut) {
+			fmt.Printf("場面転換: フレーム %d (%.3f 秒、スコア %.3f)\n", c.Frame, c.Time.Seconds(), c.Score)
+			cuts = append(cuts, c)
+		},
+	})
+	if err != nil || cutsPath == "" {
+		return err
+	}
+	data, err := json.MarshalIndent(cuts, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(cutsPath, data, 0o644); err != nil {
+		return fmt.Errorf("カット一覧の書き込みエラー: %v", err)
+	}
+	return nil
+}
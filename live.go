@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"libvpxGo/vpxgo"
+)
+
+// livePage は <video> タグでライブ配信を再生するだけのページです。
+const livePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>libvpxGo live</title></head>
+<body style="margin:0;background:#000">
+<video src="/live.webm" autoplay muted playsinline controls style="width:100%%;max-height:100vh"></video>
+<p style="color:#888;font-family:sans-serif">%s %dx%d</p>
+</body>
+</html>
+`
+
+// startLiveServer は /live.webm でライブ WebM を、/ で再生ページを配信するサーバーを起動します。
+// dash が nil でなければ /dash/ 以下で DASH のマニフェストとセグメントも配信します。
+func startLiveServer(addr string, stream *vpxgo.LiveStream, dash *vpxgo.DASHSegmenter, cfg vpxgo.EncoderConfig) {
+	mux := http.NewServeMux()
+	mux.Handle("/live.webm", stream)
+	if dash != nil {
+		mux.Handle("/dash/", http.StripPrefix("/dash/", dash))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, livePage, cfg.Codec, cfg.Width, cfg.Height)
+	})
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("ライブ配信サーバーエラー: %v", err)
+		}
+	}()
+}
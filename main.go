@@ -1,177 +1,338 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"image"
 	"log"
+	"net/http"
+	"os"
+	"strings"
 	"time"
-	"unsafe"
 
-	"github.com/xlab/libvpx-go/vpx"
+	"libvpxGo/vpxgo"
+
 	"gocv.io/x/gocv"
 )
 
+// 使用例
+func main() {
+	mode := flag.String("mode", "webcam", "動作モード: webcam, compare, sweep, encode, decode, transcode, config, roundtrip, alpha")
+	preset := flag.String("preset", "", "エンコーダーのプリセット: "+strings.Join(vpxgo.Presets(), ", ")+" (webcam/config/encode/transcode モード)")
+	profilePath := flag.String("profile", "", "エンコーダー設定のプロファイル (.json/.yaml) (webcam/config/encode/transcode モード)")
+	srcPath := flag.String("src", "", "ソース動画 (Y4M または OpenCV で読める動画ファイル) (compare/sweep/encode/transcode モード)、透過画像 (alpha モード)")
+	inPath := flag.String("in", "", "入力する IVF/WebM ファイル、- なら標準入力 (compare/alpha/decode モード)")
+	csvPath := flag.String("csv", "", "フレームごとの品質を書き出す CSV ファイル (compare モード)")
+	outPath := flag.String("out", "", "出力ファイル、拡張子 .webm なら WebM、それ以外は IVF、- なら標準出力 (encode/alpha/decode モード)")
+	codecName := flag.String("codec", "vp8", "コーデック: vp8 または vp9、-preset/-profile より優先 (webcam/config/sweep/encode/transcode モード)")
+	bitrate := flag.Int("bitrate", 1000, "目標ビットレート kbps、-preset/-profile より優先 (webcam/config/encode/transcode モード)")
+	passes := flag.Int("passes", 1, "パス数 1 または 2 (encode モード)")
+	pass := flag.Int("pass", 0, "2 パスのうち実行するパス、0 なら両方 (encode モード)")
+	fpfPath := flag.String("fpf", "", "1 パス目の統計ファイル (encode モード)")
+	width := flag.Int("width", 0, "生の I420 入力の幅 (encode モードで -src - の場合)、表示する設定の幅 (config モード、既定 640)")
+	height := flag.Int("height", 0, "生の I420 入力の高さ (encode モードで -src - の場合)、表示する設定の高さ (config モード、既定 480)")
+	fps := flag.Float64("fps", 30, "生の I420 入力のフレームレート (encode モード)、Y4M 出力のフレームレート (decode モード)")
+	format := flag.String("format", "", "標準入出力の形式: ivf, webm (encode モード)、y4m, i420 (decode モード)。空なら -out の拡張子から判定")
+	sceneCut := flag.Bool("scene-cut", false, "場面転換を検出してキーフレームを挿入する (encode/transcode モード)")
+	sceneThreshold := flag.Float64("scene-threshold", 0.1, "場面転換とみなすスコア 0..1、小さいほど敏感 (encode モード)")
+	startFrame := flag.Int("start-frame", 0, "変換を始めるソースのフレーム番号、中断した変換の再開に使う (transcode モード)")
+	endFrame := flag.Int("end-frame", 0, "このフレームの手前で変換を終える、0 なら最後まで (transcode モード)")
+	cutsPath := flag.String("cuts", "", "検出したカットの時刻を書き出す JSON ファイル、指定すると -scene-cut も有効 (encode モード)")
+	bitrates := flag.String("bitrates", "300,600,1200,2500", "カンマ区切りの目標ビットレート kbps (sweep モード)")
+	sizes := flag.String("sizes", "", "カンマ区切りの解像度 WxH、空ならソース解像度 (sweep モード)")
+	cpuUsed := flag.String("cpu-used", "0", "cpu-used 値、-preset/-profile より優先、sweep モードではカンマ区切りで複数指定 (webcam/config/sweep/encode/transcode モード)")
+	maxFrames := flag.Int("frames", 0, "エンコードする最大フレーム数、0 なら全体 (sweep/encode モード)")
+	outDir := flag.String("outdir", "", "各設定のエンコード結果 (IVF) を保存するディレクトリ (sweep モード)、復号した PNG の出力先 (alpha モード)")
+	reportPath := flag.String("report", "", "JSON レポートの出力先 (sweep モード)")
+	roiMotion := flag.Bool("roi-motion", false, "動きのある領域に多くのビットを割り当てる (webcam モード, VP8)")
+	faceCascade := flag.String("face-cascade", "", "顔領域を高画質にするための Haar カスケード XML (webcam モード, VP8)")
+	statsPath := flag.String("stats", "", "フレームごとの統計 (PSNR/SSIM/量子化値/処理時間) の出力先 (.csv または .json)")
+	bitDepth := flag.Int("bit-depth", 10, "合成フレームのビット深度 8, 10, 12 (roundtrip モード、-src 指定時は Y4M の値)")
+	chroma444 := flag.Bool("444", false, "合成フレームを 4:4:4 にする (roundtrip モード)")
+	liveAddr := flag.String("live", "", "ブラウザ再生用のライブ WebM を配信するアドレス (例: :8080、/live.webm) (webcam モード)")
+	dash := flag.Bool("dash", false, "キーフレーム単位の WebM DASH セグメントを作成する (-live 指定時は /dash/manifest.mpd で配信) (webcam モード)")
+	dashDir := flag.String("dash-dir", "", "DASH のセグメントとマニフェストを書き出すディレクトリ (webcam モード)")
+	segDur := flag.Duration("seg-dur", 4*time.Second, "DASH セグメントの目標長 (webcam モード)")
+	dashWindow := flag.Int("dash-window", 5, "マニフェストに載せる DASH セグメント数 (webcam モード)")
+	recordDir := flag.String("record", "", "連続録画のセグメントを書き出すディレクトリ (webcam モード)")
+	rotate := flag.Duration("rotate", 10*time.Minute, "録画ファイルを切り替える間隔、0 なら時間では切り替えない (webcam モード)")
+	rotateMB := flag.Int64("rotate-mb", 0, "録画ファイルを切り替えるサイズ MB、0 ならサイズでは切り替えない (webcam モード)")
+	diskBudgetMB := flag.Int64("disk-budget-mb", 0, "録画ディレクトリの上限 MB、超えたら古いものから削除 (webcam モード)")
+	eventDir := flag.String("event-dir", "", "イベント録画の出力先、-motion で動き検出時、-http 指定時は POST /event で録画開始 (webcam モード)")
+	preRoll := flag.Duration("preroll", 5*time.Second, "イベント録画に含めるイベント前の長さ (webcam モード)")
+	postRoll := flag.Duration("postroll", 10*time.Second, "最後のイベント後に録画を続ける長さ (webcam モード)")
+	motion := flag.Bool("motion", false, "背景差分による動き検出を有効にする (webcam モード)")
+	motionSensitivity := flag.Float64("motion-sensitivity", 0.5, "動き検出の感度 0..1 (webcam モード)")
+	motionMask := flag.String("motion-mask", "", "動き検出から除外する領域 x,y,w,h をセミコロン区切りで指定 (webcam モード)")
+	motionLog := flag.String("motion-log", "", "動きイベントを JSON Lines で書き出すファイル (webcam モード)")
+	motionKeyframe := flag.Bool("motion-keyframe", false, "動きの開始時にキーフレームを挿入する (webcam モード)")
+	crop := flag.String("crop", "", "キャプチャ画像から切り出す領域 x,y,w,h (webcam モード)")
+	rotation := flag.Int("rotation", 0, "キャプチャ画像の時計回りの回転 0, 90, 180, 270 (webcam モード)")
+	denoise := flag.String("denoise", "", "ノイズ除去: bilateral または nlmeans (webcam モード)")
+	timestamp := flag.Bool("timestamp", false, "現在時刻を映像に描き込む (webcam モード)")
+	watermark := flag.String("watermark", "", "映像に描き込む透かし文字列 (webcam モード)")
+	lossRecovery := flag.Int("loss-recovery", 0, "確認済みの長期参照から損失を復帰する (VP8)。値は参照を更新する間隔 (フレーム数)、-http 指定時は POST /feedback?ack=<pts> または ?loss=1 で受信側の通知を受け付ける (webcam モード)")
+	httpAddr := flag.String("http", "", "Prometheus メトリクス (/metrics) と状態 (/status) を公開するアドレス (例: :9090)、POST /rtcp で受信した RTCP を受け取りパケットロスを集計する")
+	flag.Parse()
 
-type VP8Encoder struct {
-	ctx    *vpx.CodecCtx
-	cfg    *vpx.CodecEncCfg
-	width  int
-	height int
-}
-
-func NewVP8Encoder(width, height int) (*VP8Encoder, error) {
-	// VP8エンコーダーを初期化
-	//iface := vpx.VPXCodecIfaceVP8Encoder
-  iface := vpx.EncoderIfaceVP8()
-	cfg := &vpx.CodecEncCfg{}
-	if res := vpx.CodecEncConfigDefault(iface, cfg, 0); res != vpx.CodecOk {
-		return nil, fmt.Errorf("VP8エンコーダー設定初期化失敗1: %v", res)
-	}
-
-	// 設定
-	cfg.GW = 640             // 幅 (Width)
-	cfg.GH = 480             // 高さ (Height)
-	cfg.GTimebase.Num = 1    // タイムベースの分子 (例: 1/30秒 = 30fps)
-	cfg.GTimebase.Den = 30   // タイムベースの分母
-	cfg.RcTargetBitrate = 1000 // 目標ビットレート (kbps)
-	cfg.GUsage = 1 // 1 = realtime mode for VP8 (see libvpx documentation)
-
-	// 設定が正しく反映されたか、確認のためにプリントアウト
-	fmt.Printf("Goエンコーダー設定 (cfg) - 幅: %d, 高さ: %d\n", cfg.GW, cfg.GH)
-	fmt.Printf("Goエンコーダー設定 (cfg) - タイムベース (Num/Den): %d/%d\n", cfg.GTimebase.Num, cfg.GTimebase.Den)
-	fmt.Printf("Goエンコーダー設定 (cfg) - 目標ビットレート: %d kbps\n", cfg.RcTargetBitrate)
-	fmt.Printf("Goエンコーダー設定 (cfg) - Usage: %d\n", cfg.GUsage)
-	fmt.Printf("Goエンコーダー設定 (cfg) - 全体: %+v\n", cfg)
+	// 明示的に指定されたフラグはプリセットより優先する
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
 
-	ctx := vpx.NewCodecCtx()
-	if ctx == nil {
-		fmt.Println("エラー: vpx.NewCodecCtx() が nil を返しました。")
-		return nil, fmt.Errorf("vpx.NewCodecCtx() returned nil") // ここで処理を中断
+	switch *mode {
+	case "webcam":
+	case "compare":
+		if err := runCompare(*srcPath, *inPath, *csvPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "sweep":
+		if err := runSweep(*srcPath, *codecName, *sizes, *bitrates, *cpuUsed, *maxFrames, *outDir, *reportPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "encode":
+		base, err := fileConfig(*preset, *profilePath, set, *codecName, *bitrate, *cpuUsed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *srcPath == "-" || *outPath == "-" {
+			if err := runEncodeStream(*srcPath, *outPath, *format, base, *codecName, *bitrate, *cpuUsed, *width, *height, *fps, *maxFrames, *sceneCut, *sceneThreshold); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		if err := runEncode(*srcPath, *outPath, base, *codecName, *bitrate, *cpuUsed, *passes, *pass, *fpfPath, *maxFrames, *sceneCut, *sceneThreshold, *cutsPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "transcode":
+		base, err := fileConfig(*preset, *profilePath, set, *codecName, *bitrate, *cpuUsed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := runTranscode(*srcPath, *outPath, base, *codecName, *bitrate, *cpuUsed, *startFrame, *endFrame, *sceneCut); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "decode":
+		if err := runDecode(*inPath, *outPath, *format, *fps); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "config":
+		w, h := *width, *height
+		if w == 0 && h == 0 {
+			w, h = 640, 480
+		}
+		cfg, err := flagConfig(*preset, *profilePath, w, h, set, *codecName, *bitrate, *cpuUsed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := runConfig(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "alpha":
+		if err := runAlpha(*srcPath, *inPath, *outPath, *outDir, *codecName, *bitrate, *maxFrames); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "roundtrip":
+		if err := runRoundtrip(*srcPath, *bitDepth, *chroma444, *maxFrames); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("不明なモード: %s (webcam, compare, sweep, encode, decode, transcode, config, roundtrip, alpha のいずれかを指定してください)", *mode)
 	}
-	fmt.Printf("CodecCtx が正常に割り当てられました: %p\n", ctx)
-/*    ctx_size := unsafe.Sizeof(*ctx) // Go の CodecCtx のサイズ
-    C.memset(unsafe.Pointer(ctx), 0, C.size_t(ctx_size)) // ゼロクリア実行
 
-    fmt.Println("CodecCtx のメモリをゼロクリアしました。") */
-
-	if res := vpx.CodecEncInitVer(ctx, iface, cfg, 0, vpx.EncoderABIVersion); res != vpx.CodecOk {
-		return nil, fmt.Errorf("VP8エンコーダー初期化失敗2: %v", res)
+	// GoCV初期化
+	webcam, err := gocv.OpenVideoCapture(0)
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer webcam.Close()
 	
-	fmt.Printf("VP8エンコーダー初期化成功: %v\n", ctx)
-	return &VP8Encoder{
-		ctx:    ctx,
-		cfg:    cfg,
-		width:  width,
-		height: height,
-	}, nil
-}
-
-func (e *VP8Encoder) Encode(mat gocv.Mat) ([]byte, error) {
-	// GoCVのMatからRGBデータを取得
-	img, err := mat.ToImage()
+	// VP8エンコーダー初期化
+	cfg, err := flagConfig(*preset, *profilePath, 640, 480, set, *codecName, *bitrate, *cpuUsed)
 	if err != nil {
-		return nil, fmt.Errorf("Mat変換エラー: %v", err)
+		log.Fatal(err)
 	}
-	
-	// RGBからYUV420に変換
-	yuvData := rgbToYUV420(img, e.width, e.height)
-	
-	// VP8エンコード用のイメージを作成
-	vpxImg := vpx.ImageAlloc(nil, vpx.ImageFormatI420, uint32(e.width), uint32(e.height), 1)
-	if vpxImg == nil {
-		return nil, fmt.Errorf("vpx image allocation failed")
+	cfg.EnablePSNR = *statsPath != ""
+	cfg.EnableSSIM = *statsPath != ""
+	encoder, err := vpxgo.NewEncoder(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-	// Set Y, U, V planes
-
+	defer encoder.Close()
 
-	copy((*[1 << 30]byte)(unsafe.Pointer(vpxImg.Planes[0]))[:len(yuvData[0]):len(yuvData[0])], yuvData[0])
-	copy((*[1 << 30]byte)(unsafe.Pointer(vpxImg.Planes[1]))[:len(yuvData[1]):len(yuvData[1])], yuvData[1])
-	copy((*[1 << 30]byte)(unsafe.Pointer(vpxImg.Planes[2]))[:len(yuvData[2]):len(yuvData[2])], yuvData[2])
-	
-	// エンコード実行
-	deadline := uint64(time.Now().UnixNano() / 1000) // マイクロ秒
-	if res := vpx.CodecEncode(e.ctx, vpxImg, 0, 1, 0, uint(deadline)); res != vpx.CodecOk {
-		return nil, fmt.Errorf("VP8エンコードエラー: %v", res)
-	}
-	
-	// エンコード結果を取得
-	var encoded []byte
-	var iter vpx.CodecIter = nil
-	for {
-		pkt := vpx.CodecGetCxData(e.ctx, &iter)
-		if pkt == nil {
-			break
+	if *statsPath != "" {
+		f, err := os.Create(*statsPath)
+		if err != nil {
+			log.Fatal(err)
 		}
-		pkt.Deref() // 必須
-		if pkt.Kind == vpx.CodecCxFramePkt {
-	
-			frame := (*vpx.FixedBuf)(unsafe.Pointer(pkt))
+		defer f.Close()
+		format := vpxgo.StatsCSV
+		if strings.HasSuffix(*statsPath, ".json") {
+			format = vpxgo.StatsJSON
+		}
+		encoder.SetStatsLogger(vpxgo.NewStatsLogger(f, format))
+	}
 
-			frameSz := frame.Sz
-			frameData := unsafe.Slice((*byte)(frame.Buf), frameSz)
-			encoded = append(encoded, frameData...)
+	var recovery *vpxgo.LossRecovery
+	if *lossRecovery > 0 {
+		if cfg.TemporalLayers.Layers > 0 {
+			log.Fatal("-loss-recovery はテンポラルレイヤーと併用できません")
 		}
+		recovery = vpxgo.NewLossRecovery(*lossRecovery)
+		encoder.SetLossRecovery(recovery)
 	}
-	
-	return encoded, nil
-}
 
-func (e *VP8Encoder) Close() {
-	if e.ctx != nil {
-		vpx.CodecDestroy(e.ctx)
+	var events *vpxgo.EventRecorder
+	if *eventDir != "" {
+		events, err = vpxgo.NewEventRecorder(vpxgo.EventRecorderConfig{
+			Dir:            *eventDir,
+			PreRoll:        *preRoll,
+			PostRoll:       *postRoll,
+			MaxBufferBytes: 64 << 20,
+		}, cfg.Codec, cfg.Width, cfg.Height, cfg.FPS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		events.RequestKeyframe = encoder.RequestKeyframe
+		events.OnFileClosed = func(path string) { log.Printf("イベント録画を保存しました: %s", path) }
+		defer events.Close()
 	}
-}
 
-// RGBからYUV420に変換
-func rgbToYUV420(img image.Image, width, height int) [3][]byte {
-	bounds := img.Bounds()
-	yData := make([]byte, width*height)
-	uData := make([]byte, width*height/4)
-	vData := make([]byte, width*height/4)
-	
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(b>>8)
-			
-			// RGB to YUV変換
-			Y := uint8((66*int(r8) + 129*int(g8) + 25*int(b8) + 128) >> 8 + 16)
-			U := uint8((-38*int(r8) - 74*int(g8) + 112*int(b8) + 128) >> 8 + 128)
-			V := uint8((112*int(r8) - 94*int(g8) - 18*int(b8) + 128) >> 8 + 128)
-			
-			yData[y*width+x] = Y
-			
-			// UV は 2x2 サブサンプリング
-			if y%2 == 0 && x%2 == 0 {
-				uvIndex := (y/2)*(width/2) + (x/2)
-				uData[uvIndex] = U
-				vData[uvIndex] = V
+	var motionDet *vpxgo.MotionDetector
+	if *motion {
+		masks, err := parseRects(*motionMask)
+		if err != nil {
+			log.Fatal(err)
+		}
+		motionDet, err = vpxgo.NewMotionDetector(vpxgo.MotionDetectorConfig{Sensitivity: *motionSensitivity, Masks: masks})
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer motionDet.Close()
+
+		var logEvent func(vpxgo.MotionEvent)
+		if *motionLog != "" {
+			f, err := os.Create(*motionLog)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			logEvent = vpxgo.NewMotionLogger(f)
+		}
+		motionDet.OnEvent = func(e vpxgo.MotionEvent) {
+			label := "開始"
+			if e.Type == vpxgo.MotionStop {
+				label = "終了"
+			}
+			log.Printf("動き%s: %d 領域", label, len(e.Boxes))
+			if logEvent != nil {
+				logEvent(e)
+			}
+			if e.Type == vpxgo.MotionStart && *motionKeyframe {
+				encoder.RequestKeyframe()
 			}
 		}
 	}
-	
-	return [3][]byte{yData, uData, vData}
-}
 
-// 使用例
-func main() {
-	// GoCV初期化
-	webcam, err := gocv.OpenVideoCapture(0)
-	if err != nil {
-		log.Fatal(err)
+	var metrics *vpxgo.Metrics
+	if *httpAddr != "" {
+		metrics = vpxgo.NewMetrics()
+		encoder.SetMetrics(metrics)
+		srv := vpxgo.NewStatusServer(*httpAddr, metrics, encoder)
+		if events != nil {
+			srv.Handler.(*http.ServeMux).Handle("/event", events)
+		}
+		if recovery != nil {
+			srv.Handler.(*http.ServeMux).Handle("/feedback", recovery)
+		}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil {
+				log.Printf("監視用 HTTP サーバーエラー: %v", err)
+			}
+		}()
 	}
-	defer webcam.Close()
 	
-	// VP8エンコーダー初期化
-	encoder, err := NewVP8Encoder(640, 480)
+	if (*liveAddr != "" || *dash || *dashDir != "" || *recordDir != "" || *eventDir != "") && cfg.SVC.SpatialLayers > 0 {
+		log.Fatal("WebM 配信は VP9 SVC と併用できません")
+	}
+	var segmenter *vpxgo.DASHSegmenter
+	if *dash || *dashDir != "" {
+		if *liveAddr == "" && *dashDir == "" {
+			log.Fatal("DASH 出力には -live または -dash-dir を指定してください")
+		}
+		segmenter, err = vpxgo.NewDASHSegmenter(vpxgo.DASHConfig{SegmentDuration: *segDur, WindowSize: *dashWindow, Dir: *dashDir},
+			cfg.Codec, cfg.Width, cfg.Height, cfg.FPS, cfg.BitrateKbps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		segmenter.RequestKeyframe = encoder.RequestKeyframe
+		defer segmenter.Close()
+	}
+	var recorder *vpxgo.SegmentRecorder
+	if *recordDir != "" {
+		recorder, err = vpxgo.NewSegmentRecorder(vpxgo.RecorderConfig{
+			Dir:         *recordDir,
+			MaxDuration: *rotate,
+			MaxBytes:    *rotateMB << 20,
+			DiskBudget:  *diskBudgetMB << 20,
+		}, cfg.Codec, cfg.Width, cfg.Height, cfg.FPS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		recorder.RequestKeyframe = encoder.RequestKeyframe
+		defer recorder.Close()
+	}
+	var live *vpxgo.LiveStream
+	if *liveAddr != "" {
+		live = vpxgo.NewLiveStream(cfg.Codec, cfg.Width, cfg.Height, cfg.FPS)
+		live.RequestKeyframe = encoder.RequestKeyframe
+		defer live.Close()
+		startLiveServer(*liveAddr, live, segmenter, cfg)
+	}
+
+	// キャプチャ解像度に関わらずエンコーダーのサイズに合わせる
+	preCfg := vpxgo.PreprocessConfig{
+		Rotate:    *rotation,
+		Width:     cfg.Width,
+		Height:    cfg.Height,
+		Denoise:   vpxgo.DenoiseMode(*denoise),
+		Timestamp: *timestamp,
+		Text:      *watermark,
+	}
+	if *crop != "" {
+		rects, err := parseRects(*crop)
+		if err != nil || len(rects) != 1 {
+			log.Fatalf("-crop は x,y,w,h で 1 つだけ指定してください: %q", *crop)
+		}
+		preCfg.Crop = rects[0]
+	}
+	pre, err := vpxgo.NewPreprocessor(preCfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer encoder.Close()
-	
+	defer pre.Close()
+
+	var roi *vpxgo.ROIDetector
+	if *roiMotion || *faceCascade != "" {
+		roi, err = vpxgo.NewROIDetector(vpxgo.ROIDetectorConfig{CascadePath: *faceCascade, Motion: *roiMotion})
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer roi.Close()
+	}
+
 	mat := gocv.NewMat()
 	defer mat.Close()
+
+	// 設定のフレームレートでキャプチャとエンコードを繰り返す
+	ticker := time.NewTicker(time.Second / time.Duration(cfg.FPS))
+	defer ticker.Stop()
 	
 	// 連続エンコード
 	for {
@@ -179,21 +340,80 @@ func main() {
 			break
 		}
 		
+		if metrics != nil {
+			metrics.FrameCaptured()
+		}
+
 		if mat.Empty() {
+			if metrics != nil {
+				metrics.FrameDropped()
+			}
 			continue
 		}
 		
+		frame, err := pre.Process(mat)
+		if err != nil {
+			log.Printf("前処理エラー: %v", err)
+			if metrics != nil {
+				metrics.FrameDropped()
+			}
+			continue
+		}
+
+		if motionDet != nil {
+			boxes, err := motionDet.Detect(frame)
+			if err != nil {
+				log.Printf("動き検出エラー: %v", err)
+			} else if len(boxes) > 0 && events != nil {
+				// 動きがある間はポストロールを延長し続ける
+				if err := events.Trigger(); err != nil {
+					log.Printf("イベント録画エラー: %v", err)
+				}
+			}
+		}
+
+		if roi != nil {
+			if err := roi.Apply(encoder, frame); err != nil {
+				log.Printf("ROI 設定エラー: %v", err)
+			}
+		}
+
 		// VP8エンコード
-		encoded, err := encoder.Encode(mat)
+		packets, err := encoder.EncodeFrame(frame, 0)
 		if err != nil {
 			log.Printf("エンコードエラー: %v", err)
+			if metrics != nil {
+				metrics.FrameDropped()
+			}
 			continue
 		}
+		encoded := 0
+		for _, p := range packets {
+			encoded += len(p.Data)
+			if live != nil {
+				live.WritePacket(p)
+			}
+			if recorder != nil {
+				if err := recorder.WritePacket(p); err != nil {
+					log.Printf("録画エラー: %v", err)
+				}
+			}
+			if events != nil {
+				if err := events.WritePacket(p); err != nil {
+					log.Printf("イベント録画エラー: %v", err)
+				}
+			}
+			if segmenter != nil {
+				if err := segmenter.WritePacket(p); err != nil {
+					log.Printf("DASH セグメント書き込みエラー: %v", err)
+				}
+			}
+		}
 		
 		// WebRTCに送信 (ここでWebRTCライブラリを使用)
-		fmt.Printf("VP8フレーム生成: %d bytes\n", len(encoded))
+		fmt.Printf("VP8フレーム生成: %d bytes\n", encoded)
 		
-		// 30fps制御
-		time.Sleep(33 * time.Millisecond)
+		// フレームレート制御 (処理にかかった時間を差し引いて待つ)
+		<-ticker.C
 	}
 }
\ No newline at end of file
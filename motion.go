@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// parseRects は "x,y,w,h;x,y,w,h" 形式の矩形リストを解析します。
+func parseRects(s string) ([]image.Rectangle, error) {
+	var rects []image.Rectangle
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ",")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("矩形は x,y,w,h で指定してください: %q", item)
+		}
+		var v [4]int
+		for i, p := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("矩形の値が不正です: %q", item)
+			}
+			v[i] = n
+		}
+		rects = append(rects, image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]))
+	}
+	return rects, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"libvpxGo/vpxgo"
+)
+
+// openInput は "-" なら標準入力、それ以外はファイルを開きます。
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// createOutput は "-" なら標準出力、それ以外はファイルを作成します。
+// 標準出力は閉じない。
+func createOutput(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// streamFormat は -format が空のとき出力ファイルの拡張子から形式を決めます。
+func streamFormat(format, outPath, def string) string {
+	if format != "" {
+		return format
+	}
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(outPath)), "."); ext != "" && outPath != "-" {
+		if ext == "yuv" {
+			return "i420"
+		}
+		return ext
+	}
+	return def
+}
+
+// runEncodeStream は encode モードで -src または -out に "-" を指定したときの処理です。
+// 標準出力はデータに使うので、進捗とカットの情報は標準エラーに書く。
+func runEncodeStream(srcPath, outPath, format string, base *vpxgo.EncoderConfig, codecName string, kbps int, cpuUsed string, width, height int, fps float64, maxFrames int, sceneCut bool, sceneThreshold float64) error {
+	if srcPath == "" || outPath == "" {
+		return fmt.Errorf("-src と -out を指定してください (標準入出力は -)")
+	}
+	codec, err := vpxgo.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	cpu, err := strconv.Atoi(cpuUsed)
+	if err != nil {
+		return fmt.Errorf("-cpu-used は整数で指定してください: %q", cpuUsed)
+	}
+
+	in, err := openInput(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, closeOut, err := createOutput(outPath)
+	if err != nil {
+		return err
+	}
+
+	err = vpxgo.EncodeStream(in, out, vpxgo.StreamEncodeOptions{
+		FileEncodeOptions: vpxgo.FileEncodeOptions{
+			Codec:          codec,
+			BitrateKbps:    kbps,
+			CpuUsed:        cpu,
+			Config:         base,
+			MaxFrames:      maxFrames,
+			SceneCut:       sceneCut,
+			SceneThreshold: sceneThreshold,
+			OnSceneCut: func(c vpxgo.SceneCut) {
+				fmt.Fprintf(os.Stderr, "場面転換: フレーム %d (%.3f 秒、スコア %.3f)\n", c.Frame, c.Time.Seconds(), c.Score)
+			},
+		},
+		Width:  width,
+		Height: height,
+		FPS:    fps,
+		Format: streamFormat(format, outPath, "ivf"),
+	}, os.Stderr)
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// runDecode は decode モードの処理です。IVF/WebM を Y4M または生の I420 に復号する。
+func runDecode(inPath, outPath, format string, fps float64) error {
+	if inPath == "" || outPath == "" {
+		return fmt.Errorf("-in と -out を指定してください (標準入出力は -)")
+	}
+	in, err := openInput(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, closeOut, err := createOutput(outPath)
+	if err != nil {
+		return err
+	}
+
+	n, err := vpxgo.DecodeStream(in, out, vpxgo.StreamDecodeOptions{
+		Format: streamFormat(format, outPath, "y4m"),
+		FPS:    fps,
+	})
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "復号完了: %d フレーム\n", n)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+
+	"libvpxGo/vpxgo"
+)
+
+// runRoundtrip は roundtrip モードの処理です。VP9 可逆符号化でエンコード・デコードし、
+// 復号結果がソースとビット単位で一致するかを確認します。
+func runRoundtrip(srcPath string, bitDepth int, chroma444 bool, maxFrames int) error {
+	var frames []*vpxgo.Frame16
+	fps := 30
+	if srcPath != "" {
+		f, err := os.Open(srcPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		y4m, err := vpxgo.NewY4MReader(f)
+		if err != nil {
+			return err
+		}
+		if y4m.FPS() >= 1 {
+			fps = int(y4m.FPS() + 0.5)
+		}
+		for maxFrames <= 0 || len(frames) < maxFrames {
+			frame, err := y4m.ReadFrame16()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			frames = append(frames, frame)
+		}
+	} else {
+		n := maxFrames
+		if n <= 0 {
+			n = 10
+		}
+		frames = syntheticFrames16(320, 240, bitDepth, chroma444, n)
+	}
+
+	res, err := vpxgo.VerifyRoundtrip(frames, fps)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d フレーム (%d ビット, 4:4:4=%v) -> %d バイト, 復号 %d フレーム\n",
+		res.Frames, frames[0].BitDepth, frames[0].Chroma444, res.Bytes, res.Decoded)
+	if !res.BitExact() {
+		return fmt.Errorf("復号結果がソースと一致しません (不一致フレーム: %v)", res.Mismatches)
+	}
+	fmt.Println("ビット単位で一致しました")
+	return nil
+}
+
+// syntheticFrames16 はグラデーションとノイズを含む検証用フレームを生成します。
+// ノイズで可逆性の確認がグラデーションだけの場合より厳しくなります。
+func syntheticFrames16(width, height, bitDepth int, chroma444 bool, n int) []*vpxgo.Frame16 {
+	rng := rand.New(rand.NewSource(1))
+	maxVal := 1<<uint(bitDepth) - 1
+	frames := make([]*vpxgo.Frame16, n)
+	for i := range frames {
+		f := vpxgo.NewFrame16(width, height, bitDepth, chroma444)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := ((x+i*4)*maxVal/width + rng.Intn(16)) % (maxVal + 1)
+				f.Y[y*width+x] = uint16(v)
+			}
+		}
+		cw := f.ChromaWidth()
+		for y := 0; y < f.ChromaHeight(); y++ {
+			for x := 0; x < cw; x++ {
+				f.U[y*cw+x] = uint16((y*maxVal/f.ChromaHeight() + rng.Intn(8)) % (maxVal + 1))
+				f.V[y*cw+x] = uint16((maxVal - x*maxVal/cw + rng.Intn(8)) % (maxVal + 1))
+			}
+		}
+		frames[i] = f
+	}
+	return frames
+}
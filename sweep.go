@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"libvpxGo/vpxgo"
+)
+
+// parseSweepPoints builds the cartesian product of the comma separated lists
+// of sizes ("640x360,1280x720"), bitrates ("300,600") and cpu-used values.
+// An empty size list means the source resolution.
+func parseSweepPoints(sizes, bitrates, cpuUsed string) ([]vpxgo.SweepPoint, error) {
+	parseInts := func(s string) ([]int, error) {
+		var out []int
+		for _, f := range strings.Split(s, ",") {
+			if f = strings.TrimSpace(f); f == "" {
+				continue
+			}
+			v, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("数値ではありません: %q", f)
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+
+	rates, err := parseInts(bitrates)
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("ビットレートを 1 つ以上指定してください")
+	}
+	cpus, err := parseInts(cpuUsed)
+	if err != nil {
+		return nil, err
+	}
+	if len(cpus) == 0 {
+		cpus = []int{0}
+	}
+	dims := [][2]int{{0, 0}}
+	if strings.TrimSpace(sizes) != "" {
+		dims = nil
+		for _, f := range strings.Split(sizes, ",") {
+			var w, h int
+			if _, err := fmt.Sscanf(strings.TrimSpace(f), "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+				return nil, fmt.Errorf("解像度は WxH で指定してください: %q", f)
+			}
+			dims = append(dims, [2]int{w, h})
+		}
+	}
+
+	var points []vpxgo.SweepPoint
+	for _, d := range dims {
+		for _, r := range rates {
+			for _, c := range cpus {
+				points = append(points, vpxgo.SweepPoint{Width: d[0], Height: d[1], BitrateKbps: r, CpuUsed: c})
+			}
+		}
+	}
+	return points, nil
+}
+
+// runSweep は sweep モードの処理です。
+func runSweep(srcPath, codecName, sizes, bitrates, cpuUsed string, maxFrames int, outDir, jsonPath string) error {
+	if srcPath == "" {
+		return fmt.Errorf("-src を指定してください")
+	}
+	codec, err := vpxgo.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	points, err := parseSweepPoints(sizes, bitrates, cpuUsed)
+	if err != nil {
+		return err
+	}
+	if outDir != "" {
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	report, err := vpxgo.RunSweep(srcPath, vpxgo.SweepOptions{Codec: codec, Points: points, MaxFrames: maxFrames, OutDir: outDir})
+	if err != nil {
+		return err
+	}
+	if err := vpxgo.WriteSweepTable(os.Stdout, report); err != nil {
+		return err
+	}
+
+	if jsonPath != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(jsonPath, data, 0o644); err != nil {
+			return fmt.Errorf("レポート書き込みエラー: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"libvpxGo/vpxgo"
+)
+
+// runTranscode は transcode モードの処理です。Ctrl-C で中断してもファイルは
+// 閉じられ、続きを変換するための -start-frame を表示する。
+func runTranscode(srcPath, outPath string, base *vpxgo.EncoderConfig, codecName string, kbps int, cpuUsed string, startFrame, endFrame int, sceneCut bool) error {
+	if srcPath == "" || outPath == "" {
+		return fmt.Errorf("-src と -out を指定してください")
+	}
+	if !strings.HasSuffix(strings.ToLower(outPath), ".webm") {
+		return fmt.Errorf("transcode モードの出力は .webm で指定してください: %s", outPath)
+	}
+	codec, err := vpxgo.ParseCodec(codecName)
+	if err != nil {
+		return err
+	}
+	cpu, err := strconv.Atoi(cpuUsed)
+	if err != nil {
+		return fmt.Errorf("-cpu-used は整数で指定してください: %q", cpuUsed)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	res, err := vpxgo.Transcode(ctx, srcPath, outPath, vpxgo.TranscodeOptions{
+		Codec:       codec,
+		BitrateKbps: kbps,
+		CpuUsed:     cpu,
+		Config:      base,
+		SceneCut:    sceneCut,
+		StartFrame:  startFrame,
+		EndFrame:    endFrame,
+		Progress: func(p vpxgo.TranscodeProgress) {
+			if p.Total > 0 {
+				fmt.Printf("フレーム %d/%d (%.1f%%)  %.1f fps  %d KB  残り %s\n",
+					p.Frame, p.Total, float64(p.Frame)*100/float64(p.Total), p.FPS, p.Bytes/1024, p.ETA.Round(time.Second))
+			} else {
+				fmt.Printf("フレーム %d  %.1f fps  %d KB\n", p.Frame, p.FPS, p.Bytes/1024)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("変換エラー (フレーム %d): %w", res.NextFrame, err)
+	}
+
+	fmt.Printf("変換完了: %d フレーム, %s, %d bytes -> %s\n", res.Frames, res.Duration.Round(time.Millisecond), res.Bytes, outPath)
+	if res.Interrupted {
+		fmt.Printf("中断しました。続きは -start-frame %d で別ファイルに変換できます\n", res.NextFrame)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Package vpxext は github.com/xlab/libvpx-go/vpx が公開していない libvpx の
+// API (vpx_codec_control や出力パケットの union メンバなど) を補う cgo ラッパーです。
+// vpx パッケージの型 (CodecCtx, CodecCxPkt, ...) をそのまま受け取ります。
+package vpxext
+
+/*
+#cgo pkg-config: vpx
+#include <vpx/vpx_encoder.h>
+#include <vpx/vpx_image.h>
+#include <vpx/vp8cx.h>
+#include <stdlib.h>
+
+// vpx_codec_control_ は可変長引数のため cgo から直接呼べない
+static vpx_codec_err_t ext_control_int(vpx_codec_ctx_t *ctx, int id, int v) {
+	return vpx_codec_control_(ctx, id, v);
+}
+
+static vpx_codec_err_t ext_control_ptr(vpx_codec_ctx_t *ctx, int id, void *v) {
+	return vpx_codec_control_(ctx, id, v);
+}
+
+static const void *ext_frame_buf(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.frame.buf; }
+static size_t ext_frame_sz(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.frame.sz; }
+static vpx_codec_pts_t ext_frame_pts(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.frame.pts; }
+static unsigned long ext_frame_duration(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.frame.duration; }
+static vpx_codec_frame_flags_t ext_frame_flags(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.frame.flags; }
+static int ext_frame_partition_id(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.frame.partition_id; }
+static const void *ext_stats_buf(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.twopass_stats.buf; }
+static size_t ext_stats_sz(const vpx_codec_cx_pkt_t *pkt) { return pkt->data.twopass_stats.sz; }
+static const struct vpx_psnr_pkt *ext_psnr(const vpx_codec_cx_pkt_t *pkt) { return &pkt->data.psnr; }
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// VP8 のフレーム単位エンコードフラグ (vp8cx.h の VP8_EFLAG_*)。
+// vpx.CodecEncode の flags 引数に vpx.EflagForceKf と組み合わせて渡します。
+const (
+	EflagNoRefLast    vpx.EncFrameFlags = C.VP8_EFLAG_NO_REF_LAST
+	EflagNoRefGf      vpx.EncFrameFlags = C.VP8_EFLAG_NO_REF_GF
+	EflagNoRefArf     vpx.EncFrameFlags = C.VP8_EFLAG_NO_REF_ARF
+	EflagNoUpdLast    vpx.EncFrameFlags = C.VP8_EFLAG_NO_UPD_LAST
+	EflagNoUpdGf      vpx.EncFrameFlags = C.VP8_EFLAG_NO_UPD_GF
+	EflagNoUpdArf     vpx.EncFrameFlags = C.VP8_EFLAG_NO_UPD_ARF
+	EflagForceGf      vpx.EncFrameFlags = C.VP8_EFLAG_FORCE_GF
+	EflagForceArf     vpx.EncFrameFlags = C.VP8_EFLAG_FORCE_ARF
+	EflagNoUpdEntropy vpx.EncFrameFlags = C.VP8_EFLAG_NO_UPD_ENTROPY
+)
+
+// ControlID は vpx_codec_control に渡す制御 ID (vp8cx.h の VP8E_* / VP9E_*) です。
+type ControlID int
+
+const (
+	Vp8eSetRoiMap             ControlID = C.VP8E_SET_ROI_MAP
+	Vp8eSetActiveMap          ControlID = C.VP8E_SET_ACTIVEMAP
+	Vp8eSetCpuused            ControlID = C.VP8E_SET_CPUUSED
+	Vp8eSetEnableAutoAltRef   ControlID = C.VP8E_SET_ENABLEAUTOALTREF
+	Vp8eSetNoiseSensitivity   ControlID = C.VP8E_SET_NOISE_SENSITIVITY
+	Vp8eSetSharpness          ControlID = C.VP8E_SET_SHARPNESS
+	Vp8eSetStaticThreshold    ControlID = C.VP8E_SET_STATIC_THRESHOLD
+	Vp8eSetTokenPartitions    ControlID = C.VP8E_SET_TOKEN_PARTITIONS
+	Vp8eSetArnrMaxFrames      ControlID = C.VP8E_SET_ARNR_MAXFRAMES
+	Vp8eSetArnrStrength       ControlID = C.VP8E_SET_ARNR_STRENGTH
+	Vp8eSetTuning             ControlID = C.VP8E_SET_TUNING
+	Vp8eSetMaxIntraBitratePct ControlID = C.VP8E_SET_MAX_INTRA_BITRATE_PCT
+	Vp8eSetScreenContentMode  ControlID = C.VP8E_SET_SCREEN_CONTENT_MODE
+	Vp8eSetTemporalLayerID    ControlID = C.VP8E_SET_TEMPORAL_LAYER_ID
+	Vp8eGetLastQuantizer64    ControlID = C.VP8E_GET_LAST_QUANTIZER_64
+	Vp9eSetLossless           ControlID = C.VP9E_SET_LOSSLESS
+	Vp9eSetNoiseSensitivity   ControlID = C.VP9E_SET_NOISE_SENSITIVITY
+	Vp9eSetTuneContent        ControlID = C.VP9E_SET_TUNE_CONTENT
+	Vp9eSetSvc                ControlID = C.VP9E_SET_SVC
+	Vp9eSetSvcParameters      ControlID = C.VP9E_SET_SVC_PARAMETERS
+	Vp9eGetSvcLayerID         ControlID = C.VP9E_GET_SVC_LAYER_ID
+)
+
+// VP9 SVC の時間レイヤー構成 (vp9e_temporal_layering_mode)。
+const (
+	Vp9eTemporalLayeringModeNoLayering = C.VP9E_TEMPORAL_LAYERING_MODE_NOLAYERING
+	Vp9eTemporalLayeringModeBypass     = C.VP9E_TEMPORAL_LAYERING_MODE_BYPASS
+	Vp9eTemporalLayeringMode0101       = C.VP9E_TEMPORAL_LAYERING_MODE_0101
+	Vp9eTemporalLayeringMode0212       = C.VP9E_TEMPORAL_LAYERING_MODE_0212
+)
+
+// VP8E_SET_TUNING の値 (vp8e_tuning)。
+const (
+	TunePSNR = C.VP8_TUNE_PSNR
+	TuneSSIM = C.VP8_TUNE_SSIM
+)
+
+// VP9E_SET_TUNE_CONTENT の値 (vp9e_tune_content)。
+const (
+	Vp9eContentDefault = C.VP9E_CONTENT_DEFAULT
+	Vp9eContentScreen  = C.VP9E_CONTENT_SCREEN
+)
+
+// ErrorDetail は直前のエラーについて libvpx が記録した詳細メッセージを返します。
+func ErrorDetail(ctx *vpx.CodecCtx) string {
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	if d := C.vpx_codec_error_detail(cctx); d != nil {
+		return C.GoString(d)
+	}
+	return ""
+}
+
+// ControlInt は int 型の値を取る制御を vpx_codec_control で設定します。
+func ControlInt(ctx *vpx.CodecCtx, id ControlID, value int) vpx.CodecErr {
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	return vpx.CodecErr(C.ext_control_int(cctx, C.int(id), C.int(value)))
+}
+
+// ControlGetInt は int* を受け取る取得系の制御 (VP8E_GET_LAST_QUANTIZER など) を呼び出します。
+func ControlGetInt(ctx *vpx.CodecCtx, id ControlID) (int, vpx.CodecErr) {
+	var v C.int
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	res := vpx.CodecErr(C.ext_control_ptr(cctx, C.int(id), unsafe.Pointer(&v)))
+	return int(v), res
+}
+
+// FramePacket は VPX_CODEC_CX_FRAME_PKT パケットの内容です。
+// Data は Go 側にコピー済みなので次の vpx.CodecEncode 呼び出し後も有効です。
+type FramePacket struct {
+	Data        []byte
+	PTS         int64
+	Duration    uint64
+	Flags       vpx.CodecFrameFlags
+	PartitionID int
+}
+
+// Frame は vpx.CodecGetCxData が返したフレームパケットの中身を取り出します。
+func Frame(pkt *vpx.CodecCxPkt) FramePacket {
+	cpkt := (*C.vpx_codec_cx_pkt_t)(unsafe.Pointer(pkt.Ref()))
+	return FramePacket{
+		Data:        C.GoBytes(C.ext_frame_buf(cpkt), C.int(C.ext_frame_sz(cpkt))),
+		PTS:         int64(C.ext_frame_pts(cpkt)),
+		Duration:    uint64(C.ext_frame_duration(cpkt)),
+		Flags:       vpx.CodecFrameFlags(C.ext_frame_flags(cpkt)),
+		PartitionID: int(C.ext_frame_partition_id(cpkt)),
+	}
+}
+
+// PSNRPacket は VPX_CODEC_PSNR_PKT パケットの内容です。
+// 各配列の添字は 0=全体, 1=Y, 2=U, 3=V です。
+type PSNRPacket struct {
+	Samples [4]uint32
+	SSE     [4]uint64
+	PSNR    [4]float64
+}
+
+// PSNR は VPX_CODEC_USE_PSNR で初期化したエンコーダーの PSNR パケットを取り出します。
+func PSNR(pkt *vpx.CodecCxPkt) PSNRPacket {
+	cpkt := (*C.vpx_codec_cx_pkt_t)(unsafe.Pointer(pkt.Ref()))
+	c := C.ext_psnr(cpkt)
+	var p PSNRPacket
+	for i := 0; i < 4; i++ {
+		p.Samples[i] = uint32(c.samples[i])
+		p.SSE[i] = uint64(c.sse[i])
+		p.PSNR[i] = float64(c.psnr[i])
+	}
+	return p
+}
+
+// TwoPassStats は 1 パス目の VPX_CODEC_STATS_PKT パケットの統計データを取り出します。
+func TwoPassStats(pkt *vpx.CodecCxPkt) []byte {
+	cpkt := (*C.vpx_codec_cx_pkt_t)(unsafe.Pointer(pkt.Ref()))
+	return C.GoBytes(C.ext_stats_buf(cpkt), C.int(C.ext_stats_sz(cpkt)))
+}
+
+// NewFixedBuf は data を C メモリにコピーした vpx_fixed_buf_t を返します。
+// rc_twopass_stats_in のように libvpx がエンコード中ずっと参照するバッファは
+// Go のメモリを渡せないため、これを使い、不要になったら FreeFixedBuf で解放します。
+func NewFixedBuf(data []byte) vpx.FixedBuf {
+	return vpx.FixedBuf{Buf: C.CBytes(data), Sz: uint(len(data))}
+}
+
+// FreeFixedBuf は NewFixedBuf で確保したメモリを解放します。
+func FreeFixedBuf(b vpx.FixedBuf) {
+	C.free(b.Buf)
+}
+
+// SetImageBitDepth は vpx_image_t の bit_depth を設定します。
+// vpx_img_alloc は 16 ビット形式 (I42016 など) に 16 を設定するため、
+// 10/12 ビットの入力ではエンコード前に実際のビット深度へ書き換える必要があります。
+// vpx.Image の Go 側フィールドは C 側に反映されないため C の構造体を直接書き換えます。
+func SetImageBitDepth(img *vpx.Image, depth int) {
+	cimg := (*C.vpx_image_t)(unsafe.Pointer(img.Ref()))
+	cimg.bit_depth = C.uint(depth)
+	img.BitDepth = uint32(depth)
+}
+
+// ROIMap は VP8E_SET_ROI_MAP に渡す vpx_roi_map_t です。
+// Map は 16x16 マクロブロックごとのセグメント番号 (0..3) で、nil なら ROI を無効にします。
+type ROIMap struct {
+	Map             []byte
+	Rows, Cols      int
+	DeltaQ          [4]int
+	DeltaLF         [4]int
+	StaticThreshold [4]uint
+}
+
+// SetROIMap はセグメントごとの量子化/ループフィルタ補正を設定します (VP8 のみ)。
+// libvpx はマップをコピーするため、呼び出し後に Map を再利用して構いません。
+func SetROIMap(ctx *vpx.CodecCtx, m ROIMap) vpx.CodecErr {
+	var c C.vpx_roi_map_t
+	if m.Map != nil {
+		c.roi_map = (*C.uchar)(C.CBytes(m.Map))
+		defer C.free(unsafe.Pointer(c.roi_map))
+	}
+	c.rows = C.uint(m.Rows)
+	c.cols = C.uint(m.Cols)
+	for i := 0; i < 4; i++ {
+		c.delta_q[i] = C.int(m.DeltaQ[i])
+		c.delta_lf[i] = C.int(m.DeltaLF[i])
+		c.static_threshold[i] = C.uint(m.StaticThreshold[i])
+	}
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	return vpx.CodecErr(C.ext_control_ptr(cctx, C.int(Vp8eSetRoiMap), unsafe.Pointer(&c)))
+}
+
+// SetActiveMap は 16x16 マクロブロックごとの符号化の有無 (1/0) を設定します。
+// 0 のマクロブロックは前フレームからそのままコピーされます。nil なら無効にします。
+func SetActiveMap(ctx *vpx.CodecCtx, active []byte, rows, cols int) vpx.CodecErr {
+	var c C.vpx_active_map_t
+	if active != nil {
+		c.active_map = (*C.uchar)(C.CBytes(active))
+		defer C.free(unsafe.Pointer(c.active_map))
+	}
+	c.rows = C.uint(rows)
+	c.cols = C.uint(cols)
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	return vpx.CodecErr(C.ext_control_ptr(cctx, C.int(Vp8eSetActiveMap), unsafe.Pointer(&c)))
+}
+
+// SVCParameters は VP9E_SET_SVC_PARAMETERS に渡す vpx_svc_extra_cfg_t です。
+// 配列の添字はレイヤー番号 (空間レイヤー * 時間レイヤー数 + 時間レイヤー) です。
+type SVCParameters struct {
+	MaxQuantizers        [vpx.MaxLayers]int
+	MinQuantizers        [vpx.MaxLayers]int
+	ScalingFactorNum     [vpx.MaxLayers]int
+	ScalingFactorDen     [vpx.MaxLayers]int
+	TemporalLayeringMode int
+}
+
+// SetSVCParameters は VP9 SVC のレイヤーごとの量子化範囲と縮小率を設定します。
+func SetSVCParameters(ctx *vpx.CodecCtx, p SVCParameters) vpx.CodecErr {
+	var c C.vpx_svc_extra_cfg_t
+	for i := 0; i < vpx.MaxLayers; i++ {
+		c.max_quantizers[i] = C.int(p.MaxQuantizers[i])
+		c.min_quantizers[i] = C.int(p.MinQuantizers[i])
+		c.scaling_factor_num[i] = C.int(p.ScalingFactorNum[i])
+		c.scaling_factor_den[i] = C.int(p.ScalingFactorDen[i])
+	}
+	c.temporal_layering_mode = C.int(p.TemporalLayeringMode)
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	return vpx.CodecErr(C.ext_control_ptr(cctx, C.int(Vp9eSetSvcParameters), unsafe.Pointer(&c)))
+}
+
+// SVCLayerID は直前にエンコードしたフレームの空間/時間レイヤー番号を返します。
+func SVCLayerID(ctx *vpx.CodecCtx) (spatial, temporal int, res vpx.CodecErr) {
+	var id C.vpx_svc_layer_id_t
+	cctx := (*C.vpx_codec_ctx_t)(unsafe.Pointer(ctx))
+	res = vpx.CodecErr(C.ext_control_ptr(cctx, C.int(Vp9eGetSvcLayerID), unsafe.Pointer(&id)))
+	return int(id.spatial_layer_id), int(id.temporal_layer_id), res
+}
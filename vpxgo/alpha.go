@@ -0,0 +1,235 @@
+package vpxgo
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/xlab/libvpx-go/vpx"
+	"gocv.io/x/gocv"
+)
+
+// AlphaPacket is a colour packet together with the alpha stream frame that
+// belongs to it. Alpha is nil when the alpha encoder produced no frame.
+type AlphaPacket struct {
+	Packet
+	Alpha []byte
+}
+
+// AlphaEncoder encodes BGRA input as two streams: the colour image and the
+// alpha plane as a second greyscale stream, the layout WebM uses for
+// transparent VP8/VP9 (AlphaMode + BlockAdditional). Keyframes of both
+// streams are kept aligned so every colour keyframe can be decoded with its
+// alpha, and the alpha stream is restarted with a keyframe whenever a frame
+// of either stream was dropped.
+type AlphaEncoder struct {
+	color *Encoder
+	alpha *Encoder
+
+	width  int
+	height int
+	// alphaGap は対応の取れないフレームがあり、受信側のアルファの参照が
+	// 途切れていることを示す (次のアルファをキーフレームにする)
+	alphaGap bool
+}
+
+// NewAlphaEncoder creates the colour encoder from c and an alpha encoder with
+// the same settings at alphaKbps. alphaKbps <= 0 uses a quarter of the colour
+// bitrate.
+func NewAlphaEncoder(c EncoderConfig, alphaKbps int) (*AlphaEncoder, error) {
+	if c.TemporalLayers.Layers > 0 || c.SVC.SpatialLayers > 0 {
+		return nil, fmt.Errorf("アルファ付きエンコードはテンポラル/空間レイヤーと併用できません")
+	}
+	if c.BitDepth > 8 || c.Chroma444 {
+		return nil, fmt.Errorf("アルファ付きエンコードは 8 ビット 4:2:0 のみ対応です")
+	}
+	color, err := NewEncoder(c)
+	if err != nil {
+		return nil, err
+	}
+
+	ac := c
+	ac.BitrateKbps = alphaKbps
+	if ac.BitrateKbps <= 0 {
+		ac.BitrateKbps = max(c.BitrateKbps/4, 1)
+	}
+	ac.EnablePSNR, ac.EnableSSIM = false, false
+	alpha, err := NewEncoder(ac)
+	if err != nil {
+		color.Close()
+		return nil, fmt.Errorf("アルファ用エンコーダー初期化失敗: %w", err)
+	}
+	return &AlphaEncoder{color: color, alpha: alpha, width: c.Width, height: c.Height}, nil
+}
+
+// Encoder returns the colour encoder, e.g. for statistics or bitrate changes.
+func (a *AlphaEncoder) Encoder() *Encoder { return a.color }
+
+// RequestKeyframe forces a keyframe in both streams on the next frame.
+func (a *AlphaEncoder) RequestKeyframe() { a.color.RequestKeyframe() }
+
+// EncodeBGRA encodes a CV_8UC4 Mat. The colour channels go to the colour
+// stream and the fourth channel to the alpha stream.
+func (a *AlphaEncoder) EncodeBGRA(mat gocv.Mat, flags vpx.EncFrameFlags) ([]AlphaPacket, error) {
+	if mat.Type() != gocv.MatTypeCV8UC4 {
+		return nil, fmt.Errorf("BGRA (CV_8UC4) の Mat を指定してください")
+	}
+	bgr := gocv.NewMat()
+	defer bgr.Close()
+	if err := gocv.CvtColor(mat, &bgr, gocv.ColorBGRAToBGR); err != nil {
+		return nil, fmt.Errorf("色変換エラー: %v", err)
+	}
+	f, err := MatToI420(bgr)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4 チャンネル目だけを取り出す
+	data := mat.ToBytes()
+	alpha := make([]byte, mat.Cols()*mat.Rows())
+	for i := range alpha {
+		alpha[i] = data[i*4+3]
+	}
+	return a.EncodeI420(f, alpha, flags)
+}
+
+// EncodeI420 encodes a colour frame with its alpha plane (Width*Height
+// bytes, 0 = transparent).
+func (a *AlphaEncoder) EncodeI420(f *I420Frame, alpha []byte, flags vpx.EncFrameFlags) ([]AlphaPacket, error) {
+	if len(alpha) != a.width*a.height {
+		return nil, fmt.Errorf("アルファプレーンのサイズが不正です: %d バイト", len(alpha))
+	}
+	packets, err := a.color.EncodeI420(f, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	// カラー側がキーフレームならアルファ側も必ずキーフレームにする
+	alphaFlags := flags
+	if a.alphaGap {
+		alphaFlags |= vpx.EflagForceKf
+	}
+	for _, p := range packets {
+		if p.Keyframe {
+			alphaFlags |= vpx.EflagForceKf
+		}
+	}
+	af := NewI420Frame(a.width, a.height)
+	copy(af.Y, alpha)
+	for i := range af.U {
+		af.U[i], af.V[i] = 128, 128
+	}
+	alphaPackets, err := a.alpha.EncodeI420(af, alphaFlags)
+	if err != nil {
+		return nil, fmt.Errorf("アルファストリーム: %w", err)
+	}
+	return a.pair(packets, alphaPackets), nil
+}
+
+// Flush drains both encoders.
+func (a *AlphaEncoder) Flush() ([]AlphaPacket, error) {
+	packets, err := a.color.Flush()
+	if err != nil {
+		return nil, err
+	}
+	alphaPackets, err := a.alpha.Flush()
+	if err != nil {
+		return nil, fmt.Errorf("アルファストリーム: %w", err)
+	}
+	return a.pair(packets, alphaPackets), nil
+}
+
+// Close releases both encoders.
+func (a *AlphaEncoder) Close() {
+	a.color.Close()
+	a.alpha.Close()
+}
+
+// pair は両ストリームのパケットを対応付け、アルファの参照が途切れたかを記録します。
+func (a *AlphaEncoder) pair(color, alpha []Packet) []AlphaPacket {
+	out, gap, keyframe := pairAlpha(color, alpha)
+	a.alphaGap = gap || (a.alphaGap && !keyframe)
+	return out
+}
+
+// pairAlpha は PTS が一致するアルファパケットをカラーパケットに対応付けます。
+// gap はどちらか一方のストリームにしかないフレーム (カラー側で捨てられたアルファ、
+// またはアルファのないカラー) があったこと、keyframe は書き出されるアルファに
+// キーフレームが含まれることを示す。
+func pairAlpha(color, alpha []Packet) (out []AlphaPacket, gap, keyframe bool) {
+	byPTS := make(map[int64]Packet, len(alpha))
+	for _, p := range alpha {
+		byPTS[p.PTS] = p
+	}
+	out = make([]AlphaPacket, len(color))
+	paired := make(map[int64]bool, len(color))
+	for i, p := range color {
+		// 同じ PTS のカラーパケット (VP8 の非表示 alt-ref など) には最初の 1 つだけ付ける
+		if ap, ok := byPTS[p.PTS]; ok {
+			out[i] = AlphaPacket{Packet: p, Alpha: ap.Data}
+			keyframe = keyframe || ap.Keyframe
+			delete(byPTS, p.PTS)
+			paired[p.PTS] = true
+			continue
+		}
+		out[i] = AlphaPacket{Packet: p}
+		if !paired[p.PTS] {
+			gap = true
+		}
+	}
+	if len(byPTS) > 0 {
+		gap = true
+	}
+	return out, gap, keyframe
+}
+
+// AlphaDecoder decodes WebM packets with an optional alpha stream into RGBA
+// images.
+type AlphaDecoder struct {
+	color *Decoder
+	alpha *Decoder
+}
+
+// NewAlphaDecoder creates decoders for the colour and alpha streams.
+func NewAlphaDecoder(codec Codec) (*AlphaDecoder, error) {
+	color, err := NewDecoder(codec)
+	if err != nil {
+		return nil, err
+	}
+	alpha, err := NewDecoder(codec)
+	if err != nil {
+		color.Close()
+		return nil, err
+	}
+	return &AlphaDecoder{color: color, alpha: alpha}, nil
+}
+
+// Decode decodes the colour data and, when present, the alpha data of p and
+// combines them. Frames without alpha data are returned fully opaque.
+func (d *AlphaDecoder) Decode(p ContainerPacket) ([]*image.NRGBA, error) {
+	frames, err := d.color.Decode(p.Data)
+	if err != nil {
+		return nil, err
+	}
+	var alphas []*I420Frame
+	if len(p.Alpha) > 0 {
+		if alphas, err = d.alpha.Decode(p.Alpha); err != nil {
+			return nil, fmt.Errorf("アルファストリーム: %w", err)
+		}
+	}
+
+	out := make([]*image.NRGBA, len(frames))
+	for i, f := range frames {
+		var alpha []byte
+		if i < len(alphas) && alphas[i].Width == f.Width && alphas[i].Height == f.Height {
+			alpha = alphas[i].Y
+		}
+		out[i] = I420ToNRGBA(f, alpha)
+	}
+	return out, nil
+}
+
+// Close releases both decoders.
+func (d *AlphaDecoder) Close() {
+	d.color.Close()
+	d.alpha.Close()
+}
@@ -0,0 +1,70 @@
+package vpxgo
+
+import "testing"
+
+func TestPairAlpha(t *testing.T) {
+	tests := []struct {
+		name          string
+		color, alpha  []Packet
+		gap, keyframe bool
+	}{
+		{
+			name:     "paired",
+			color:    []Packet{{PTS: 0, Keyframe: true}, {PTS: 1}},
+			alpha:    []Packet{{PTS: 0, Keyframe: true, Data: []byte{1}}, {PTS: 1, Data: []byte{2}}},
+			keyframe: true,
+		},
+		{
+			name:  "alpha dropped",
+			color: []Packet{{PTS: 3}},
+			gap:   true,
+		},
+		{
+			name:  "color dropped",
+			alpha: []Packet{{PTS: 3, Data: []byte{1}}},
+			gap:   true,
+		},
+		{
+			name:  "hidden alt-ref",
+			color: []Packet{{PTS: 4}, {PTS: 4}},
+			alpha: []Packet{{PTS: 4, Data: []byte{1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, gap, keyframe := pairAlpha(tt.color, tt.alpha)
+			if gap != tt.gap || keyframe != tt.keyframe {
+				t.Errorf("gap=%v keyframe=%v, want %v %v", gap, keyframe, tt.gap, tt.keyframe)
+			}
+			if len(out) != len(tt.color) {
+				t.Fatalf("%d パケット, want %d", len(out), len(tt.color))
+			}
+			attached := 0
+			for _, p := range out {
+				if p.Alpha != nil {
+					attached++
+				}
+			}
+			if want := min(len(tt.color), len(tt.alpha)); attached != want {
+				t.Errorf("アルファ付きのパケット %d, want %d", attached, want)
+			}
+		})
+	}
+}
+
+func TestAlphaEncoderGapForcesKeyframe(t *testing.T) {
+	a := &AlphaEncoder{}
+	a.pair([]Packet{{PTS: 0}}, nil)
+	if !a.alphaGap {
+		t.Fatal("アルファの欠落が記録されていません")
+	}
+	// キーフレームでないアルファが届いても参照は途切れたまま
+	a.pair([]Packet{{PTS: 1}}, []Packet{{PTS: 1, Data: []byte{1}}})
+	if !a.alphaGap {
+		t.Fatal("キーフレームの前に復帰しています")
+	}
+	a.pair([]Packet{{PTS: 2}}, []Packet{{PTS: 2, Keyframe: true, Data: []byte{1}}})
+	if a.alphaGap {
+		t.Fatal("アルファのキーフレーム後も欠落が残っています")
+	}
+}
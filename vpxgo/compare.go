@@ -0,0 +1,250 @@
+package vpxgo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// FrameQuality holds the objective quality of one decoded frame against the
+// corresponding source frame.
+type FrameQuality struct {
+	Frame     int           `json:"frame"`
+	Timestamp time.Duration `json:"timestamp"`
+	PSNRY     float64       `json:"psnr_y"`
+	PSNRU     float64       `json:"psnr_u"`
+	PSNRV     float64       `json:"psnr_v"`
+	PSNR      float64       `json:"psnr"`
+	SSIM      float64       `json:"ssim"`
+
+	sse     [3]uint64
+	samples [3]int
+}
+
+// QualitySummary aggregates FrameQuality over a whole comparison.
+type QualitySummary struct {
+	Frames     int     `json:"frames"`
+	Missing    int     `json:"missing"` // 対応するソースフレームが無かったデコードフレーム数
+	MeanPSNRY  float64 `json:"mean_psnr_y"`
+	MeanPSNRU  float64 `json:"mean_psnr_u"`
+	MeanPSNRV  float64 `json:"mean_psnr_v"`
+	MeanPSNR   float64 `json:"mean_psnr"`
+	GlobalPSNR float64 `json:"global_psnr"` // 全フレームの SSE 合計から求めた PSNR
+	MinPSNR    float64 `json:"min_psnr"`
+	P5PSNR     float64 `json:"p5_psnr"` // 下位 5% 点 (VMAF レポートの harmonic/low 指標の代わり)
+	MeanSSIM   float64 `json:"mean_ssim"`
+	MinSSIM    float64 `json:"min_ssim"`
+}
+
+// FrameSource yields source frames in display order.
+type FrameSource interface {
+	ReadFrame() (*I420Frame, error)
+	FPS() float64
+}
+
+type videoFileSource struct {
+	vc  *gocv.VideoCapture
+	mat gocv.Mat
+}
+
+func (s *videoFileSource) ReadFrame() (*I420Frame, error) {
+	if ok := s.vc.Read(&s.mat); !ok || s.mat.Empty() {
+		return nil, io.EOF
+	}
+	return MatToI420(s.mat)
+}
+
+func (s *videoFileSource) FPS() float64 { return s.vc.Get(gocv.VideoCaptureFPS) }
+
+func (s *videoFileSource) Close() error {
+	s.mat.Close()
+	return s.vc.Close()
+}
+
+// OpenFrameSource opens a Y4M file directly, or any other video file through gocv.
+func OpenFrameSource(path string) (FrameSource, io.Closer, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".y4m") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		y, err := NewY4MReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return y, f, nil
+	}
+	vc, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ソース動画オープンエラー: %v", err)
+	}
+	s := &videoFileSource{vc: vc, mat: gocv.NewMat()}
+	return s, s, nil
+}
+
+// CompareQuality decodes the IVF/WebM file at encodedPath and compares every
+// decoded frame with the source frame at the same presentation time. Times
+// are taken from the packets and counted from the first packet, so files whose
+// timestamps do not start at 0 still line up with the start of the source, and
+// packets the decoder produces no frame for do not shift later frames.
+func CompareQuality(srcPath, encodedPath string) ([]FrameQuality, QualitySummary, error) {
+	src, srcCloser, err := OpenFrameSource(srcPath)
+	if err != nil {
+		return nil, QualitySummary{}, err
+	}
+	defer srcCloser.Close()
+
+	pr, prCloser, err := OpenPacketReader(encodedPath)
+	if err != nil {
+		return nil, QualitySummary{}, err
+	}
+	defer prCloser.Close()
+
+	dec, err := NewDecoder(pr.Info().Codec)
+	if err != nil {
+		return nil, QualitySummary{}, err
+	}
+	defer dec.Close()
+
+	fps := src.FPS()
+	if fps <= 0 {
+		fps = 30
+	}
+
+	var (
+		results []FrameQuality
+		missing int
+		srcIdx  = -1
+		srcCur  *I420Frame
+		srcEOF  bool
+		firstTS time.Duration = -1 // 最初のパケットの時刻 (ソースの先頭に対応)
+	)
+	for {
+		pkt, err := pr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, QualitySummary{}, err
+		}
+		if firstTS < 0 {
+			firstTS = pkt.Timestamp
+		}
+		frames, err := dec.Decode(pkt.Data)
+		if err != nil {
+			return nil, QualitySummary{}, err
+		}
+
+		for _, f := range frames {
+			// デコーダーの出力順ではなく、最初のパケットからの時刻をソースのフレーム番号に丸めて対応付ける
+			want := int(math.Round((pkt.Timestamp - firstTS).Seconds() * fps))
+			for srcIdx < want && !srcEOF {
+				next, err := src.ReadFrame()
+				if err == io.EOF {
+					srcEOF = true
+					break
+				}
+				if err != nil {
+					return nil, QualitySummary{}, err
+				}
+				srcCur = next
+				srcIdx++
+			}
+			if srcIdx != want || srcCur == nil {
+				missing++
+				continue
+			}
+			q, err := compareFrames(srcCur, f)
+			if err != nil {
+				return nil, QualitySummary{}, fmt.Errorf("フレーム %d: %w", want, err)
+			}
+			q.Frame = want
+			q.Timestamp = pkt.Timestamp
+			results = append(results, q)
+		}
+	}
+
+	sum := summarizeQuality(results)
+	sum.Missing = missing
+	return results, sum, nil
+}
+
+func compareFrames(src, dec *I420Frame) (FrameQuality, error) {
+	if src.Width != dec.Width || src.Height != dec.Height {
+		return FrameQuality{}, fmt.Errorf("解像度が一致しません (ソース %dx%d, デコード %dx%d)",
+			src.Width, src.Height, dec.Width, dec.Height)
+	}
+	var q FrameQuality
+	a := [3][]byte{src.Y, src.U, src.V}
+	b := [3][]byte{dec.Y, dec.U, dec.V}
+	for i := range a {
+		q.sse[i] = planeSSE(a[i], b[i])
+		q.samples[i] = len(a[i])
+	}
+	q.PSNRY = psnrFromSSE(q.sse[0], q.samples[0])
+	q.PSNRU = psnrFromSSE(q.sse[1], q.samples[1])
+	q.PSNRV = psnrFromSSE(q.sse[2], q.samples[2])
+	q.PSNR = psnrFromSSE(q.sse[0]+q.sse[1]+q.sse[2], q.samples[0]+q.samples[1]+q.samples[2])
+	q.SSIM = ssimPlane(src.Y, src.Width, dec.Y, dec.Width, src.Width, src.Height)
+	return q, nil
+}
+
+func summarizeQuality(results []FrameQuality) QualitySummary {
+	s := QualitySummary{Frames: len(results)}
+	if len(results) == 0 {
+		return s
+	}
+	var sse uint64
+	var samples int
+	psnrs := make([]float64, 0, len(results))
+	s.MinPSNR, s.MinSSIM = math.Inf(1), math.Inf(1)
+	for _, q := range results {
+		s.MeanPSNRY += q.PSNRY
+		s.MeanPSNRU += q.PSNRU
+		s.MeanPSNRV += q.PSNRV
+		s.MeanPSNR += q.PSNR
+		s.MeanSSIM += q.SSIM
+		s.MinPSNR = math.Min(s.MinPSNR, q.PSNR)
+		s.MinSSIM = math.Min(s.MinSSIM, q.SSIM)
+		psnrs = append(psnrs, q.PSNR)
+		for i := range q.sse {
+			sse += q.sse[i]
+			samples += q.samples[i]
+		}
+	}
+	n := float64(len(results))
+	s.MeanPSNRY /= n
+	s.MeanPSNRU /= n
+	s.MeanPSNRV /= n
+	s.MeanPSNR /= n
+	s.MeanSSIM /= n
+	s.GlobalPSNR = psnrFromSSE(sse, samples)
+	sort.Float64s(psnrs)
+	s.P5PSNR = psnrs[int(float64(len(psnrs)-1)*0.05)]
+	return s
+}
+
+// WriteQualityCSV writes the per-frame results as CSV with a header row.
+func WriteQualityCSV(w io.Writer, results []FrameQuality) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"frame", "timestamp_ms", "psnr_y", "psnr_u", "psnr_v", "psnr", "ssim"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	for _, q := range results {
+		cw.Write([]string{
+			strconv.Itoa(q.Frame),
+			strconv.FormatInt(q.Timestamp.Milliseconds(), 10),
+			f(q.PSNRY), f(q.PSNRU), f(q.PSNRV), f(q.PSNR), f(q.SSIM),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
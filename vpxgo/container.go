@@ -0,0 +1,121 @@
+package vpxgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// ContainerPacket is one compressed frame read from an IVF or WebM file.
+type ContainerPacket struct {
+	Data      []byte
+	Timestamp time.Duration
+	Keyframe  bool
+
+	// Alpha はアルファストリームのフレーム (WebM の BlockAdditional)。なければ nil。
+	Alpha []byte
+}
+
+// StreamInfo describes the video track of a container file.
+type StreamInfo struct {
+	Codec  Codec
+	Width  int
+	Height int
+	Alpha  bool // WebM の AlphaMode が指定されている
+}
+
+// PacketReader reads compressed frames from a container in decode order.
+// ReadPacket returns io.EOF after the last frame.
+type PacketReader interface {
+	Info() StreamInfo
+	ReadPacket() (ContainerPacket, error)
+}
+
+// OpenPacketReader opens an IVF or WebM file, detected from its magic bytes.
+func OpenPacketReader(path string) (PacketReader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := NewPacketReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, f, nil
+}
+
+// NewPacketReader detects the container format of r (IVF or WebM).
+func NewPacketReader(r io.Reader) (PacketReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("コンテナ判定エラー: %v", err)
+	}
+	switch {
+	case string(magic) == "DKIF":
+		return NewIVFReader(br)
+	case magic[0] == 0x1a && magic[1] == 0x45 && magic[2] == 0xdf && magic[3] == 0xa3:
+		return NewWebMReader(br)
+	}
+	return nil, fmt.Errorf("未対応のコンテナ形式です (IVF/WebM のみ対応)")
+}
+
+// PacketWriter writes encoded packets to a container file.
+type PacketWriter interface {
+	WritePacket(p Packet) error
+	Close() error
+}
+
+// CreatePacketWriter creates path and returns a WebM writer when it ends in
+// ".webm" and an IVF writer otherwise. Close the PacketWriter before the
+// returned file.
+func CreatePacketWriter(path string, codec Codec, width, height int, fps float64) (PacketWriter, io.Closer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	format := "ivf"
+	if strings.HasSuffix(strings.ToLower(path), ".webm") {
+		format = "webm"
+	}
+	pw, err := NewPacketWriter(f, format, codec, width, height, fps)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return pw, f, nil
+}
+
+// NewPacketWriter returns a writer for format "ivf" or "webm" on w. Sizes
+// that need seeking are only filled in when w is an io.WriteSeeker, so pipes
+// must not be passed as *os.File directly (wrap them, e.g. in a
+// bufio.Writer). Non-integer frame rates such as 29.97 are stored as a
+// fractional timebase.
+func NewPacketWriter(w io.Writer, format string, codec Codec, width, height int, fps float64) (PacketWriter, error) {
+	num, den := fpsTimebase(fps)
+	switch strings.ToLower(format) {
+	case "ivf":
+		return newIVFWriter(w, codec, width, height, num, den)
+	case "webm":
+		return NewWebMWriterTimebase(w, codec, width, height, num, den)
+	}
+	return nil, fmt.Errorf("未対応のコンテナ形式です: %q (ivf または webm)", format)
+}
+
+// fpsTimebase はフレームレートを 1 フレームの長さ num/den 秒に変換します。
+// 29.97 (30000/1001) などの NTSC 系は正確な分数に、それ以外の非整数は
+// 1/1000 fps 単位で表す。
+func fpsTimebase(fps float64) (num, den int) {
+	if r := math.Round(fps); math.Abs(fps-r) < 1e-3 {
+		return 1, int(r)
+	}
+	if r := math.Round(fps * 1.001); math.Abs(fps*1.001-r) < 1e-3 {
+		return 1001, int(r) * 1000
+	}
+	return 1000, int(math.Round(fps * 1000))
+}
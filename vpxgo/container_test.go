@@ -0,0 +1,23 @@
+package vpxgo
+
+import "testing"
+
+func TestFPSTimebase(t *testing.T) {
+	tests := []struct {
+		fps      float64
+		num, den int
+	}{
+		{30, 1, 30},
+		{15, 1, 15},
+		{29.97, 1001, 30000},
+		{30000.0 / 1001, 1001, 30000},
+		{23.976, 1001, 24000},
+		{59.94, 1001, 60000},
+		{12.5, 1000, 12500},
+	}
+	for _, tt := range tests {
+		if num, den := fpsTimebase(tt.fps); num != tt.num || den != tt.den {
+			t.Errorf("fpsTimebase(%v) = %d/%d, want %d/%d", tt.fps, num, den, tt.num, tt.den)
+		}
+	}
+}
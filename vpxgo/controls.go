@@ -0,0 +1,172 @@
+package vpxgo
+
+import (
+	"fmt"
+	"strings"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// Tuning selects the metric the encoder optimises for.
+type Tuning int
+
+const (
+	TunePSNR Tuning = vpxext.TunePSNR
+	TuneSSIM Tuning = vpxext.TuneSSIM
+)
+
+func (t Tuning) MarshalText() ([]byte, error) {
+	if t == TuneSSIM {
+		return []byte("ssim"), nil
+	}
+	return []byte("psnr"), nil
+}
+
+func (t *Tuning) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "psnr":
+		*t = TunePSNR
+	case "ssim":
+		*t = TuneSSIM
+	default:
+		return fmt.Errorf("tune は psnr または ssim で指定してください: %q", b)
+	}
+	return nil
+}
+
+// CodecControls holds the optional libvpx codec controls. A nil field keeps
+// the libvpx default (or the current value at runtime).
+type CodecControls struct {
+	// CpuUsed は速度と画質のトレードオフ (VP8: -16..16, VP9: -9..9)。
+	// 絶対値が大きいほど高速・低画質。
+	CpuUsed *int `json:"cpu_used,omitempty"`
+	// NoiseSensitivity はノイズ除去の強さ (VP8: 0..6, VP9: 0..1、0 で無効)
+	NoiseSensitivity *int `json:"noise_sensitivity,omitempty"`
+	// Sharpness はループフィルタのシャープネス (0..7)
+	Sharpness *int `json:"sharpness,omitempty"`
+	// StaticThreshold 未満の変化しかないマクロブロックは符号化を省略する
+	StaticThreshold *int `json:"static_threshold,omitempty"`
+	// MaxIntraBitratePct はキーフレームの最大サイズを平均フレームサイズの % で制限する (0 で無制限)
+	MaxIntraBitratePct *int `json:"max_intra_bitrate_pct,omitempty"`
+	// ScreenContent は画面共有向けの符号化モード (VP8: 0..2, VP9: 0..1)
+	ScreenContent *int `json:"screen_content,omitempty"`
+	// Tune は最適化の指標 (PSNR または SSIM)
+	Tune *Tuning `json:"tune,omitempty"`
+	// AutoAltRef は代替参照フレーム (ARF) を自動で生成する。先読み (lag) が必要。
+	AutoAltRef *bool `json:"auto_alt_ref,omitempty"`
+	// ARNRMaxFrames/ARNRStrength は ARF 生成時の時間方向フィルタの設定 (0..15 / 0..6)
+	ARNRMaxFrames *int `json:"arnr_max_frames,omitempty"`
+	ARNRStrength  *int `json:"arnr_strength,omitempty"`
+}
+
+// codecControl は CodecControls の 1 項目を libvpx の制御に対応付けます。
+type codecControl struct {
+	name     string
+	value    *int
+	id       map[Codec]vpxext.ControlID // 対応しないコーデックは含めない
+	min, max map[Codec]int
+}
+
+func (c CodecControls) list() []codecControl {
+	both := func(id vpxext.ControlID) map[Codec]vpxext.ControlID {
+		return map[Codec]vpxext.ControlID{CodecVP8: id, CodecVP9: id}
+	}
+	rng := func(vp8, vp9 int) map[Codec]int { return map[Codec]int{CodecVP8: vp8, CodecVP9: vp9} }
+
+	var tune, autoAltRef *int
+	if c.Tune != nil {
+		v := int(*c.Tune)
+		tune = &v
+	}
+	if c.AutoAltRef != nil {
+		v := 0
+		if *c.AutoAltRef {
+			v = 1
+		}
+		autoAltRef = &v
+	}
+
+	return []codecControl{
+		{"cpu-used", c.CpuUsed, both(vpxext.Vp8eSetCpuused), rng(-16, -9), rng(16, 9)},
+		{"noise-sensitivity", c.NoiseSensitivity,
+			map[Codec]vpxext.ControlID{CodecVP8: vpxext.Vp8eSetNoiseSensitivity, CodecVP9: vpxext.Vp9eSetNoiseSensitivity},
+			rng(0, 0), rng(6, 1)},
+		{"sharpness", c.Sharpness, both(vpxext.Vp8eSetSharpness), rng(0, 0), rng(7, 7)},
+		{"static-threshold", c.StaticThreshold, both(vpxext.Vp8eSetStaticThreshold), rng(0, 0), nil},
+		{"max-intra-bitrate-pct", c.MaxIntraBitratePct, both(vpxext.Vp8eSetMaxIntraBitratePct), rng(0, 0), nil},
+		{"screen-content", c.ScreenContent,
+			map[Codec]vpxext.ControlID{CodecVP8: vpxext.Vp8eSetScreenContentMode, CodecVP9: vpxext.Vp9eSetTuneContent},
+			rng(0, 0), rng(2, vpxext.Vp9eContentScreen)},
+		{"tune", tune, both(vpxext.Vp8eSetTuning), rng(vpxext.TunePSNR, vpxext.TunePSNR), rng(vpxext.TuneSSIM, vpxext.TuneSSIM)},
+		{"auto-alt-ref", autoAltRef, both(vpxext.Vp8eSetEnableAutoAltRef), rng(0, 0), rng(1, 1)},
+		{"arnr-max-frames", c.ARNRMaxFrames, both(vpxext.Vp8eSetArnrMaxFrames), rng(0, 0), rng(15, 15)},
+		{"arnr-strength", c.ARNRStrength, both(vpxext.Vp8eSetArnrStrength), rng(0, 0), rng(6, 6)},
+	}
+}
+
+// validate は指定された値が codec で使用でき、範囲内かを確認します。
+func (c CodecControls) validate(codec Codec) error {
+	for _, ctl := range c.list() {
+		if ctl.value == nil {
+			continue
+		}
+		if _, ok := ctl.id[codec]; !ok {
+			return fmt.Errorf("%s は %s では使用できません", ctl.name, codec)
+		}
+		v := *ctl.value
+		if lo, ok := ctl.min[codec]; ok && v < lo {
+			return fmt.Errorf("%s は %d 以上で指定してください (%s): %d", ctl.name, lo, codec, v)
+		}
+		if hi, ok := ctl.max[codec]; ok && v > hi {
+			return fmt.Errorf("%s は %d 以下で指定してください (%s): %d", ctl.name, hi, codec, v)
+		}
+	}
+	return nil
+}
+
+// apply は nil でない項目を vpx_codec_control で設定します。
+func (c CodecControls) apply(ctx *vpx.CodecCtx, codec Codec) error {
+	if err := c.validate(codec); err != nil {
+		return err
+	}
+	for _, ctl := range c.list() {
+		if ctl.value == nil {
+			continue
+		}
+		if res := vpxext.ControlInt(ctx, ctl.id[codec], *ctl.value); res != vpx.CodecOk {
+			return controlError(ctx, ctl.name, *ctl.value, res)
+		}
+	}
+	return nil
+}
+
+// merge は o の nil でない項目で c を上書きしたものを返します。
+func (c CodecControls) merge(o CodecControls) CodecControls {
+	set := func(dst **int, src *int) {
+		if src != nil {
+			*dst = src
+		}
+	}
+	set(&c.CpuUsed, o.CpuUsed)
+	set(&c.NoiseSensitivity, o.NoiseSensitivity)
+	set(&c.Sharpness, o.Sharpness)
+	set(&c.StaticThreshold, o.StaticThreshold)
+	set(&c.MaxIntraBitratePct, o.MaxIntraBitratePct)
+	set(&c.ScreenContent, o.ScreenContent)
+	set(&c.ARNRMaxFrames, o.ARNRMaxFrames)
+	set(&c.ARNRStrength, o.ARNRStrength)
+	if o.Tune != nil {
+		c.Tune = o.Tune
+	}
+	if o.AutoAltRef != nil {
+		c.AutoAltRef = o.AutoAltRef
+	}
+	return c
+}
+
+// controlError は制御名と値を処理名に含めた CodecError を返します。
+func controlError(ctx *vpx.CodecCtx, name string, value int, res vpx.CodecErr) error {
+	return newCodecError(ctx, fmt.Sprintf("%s=%d の設定", name, value), res)
+}
@@ -0,0 +1,75 @@
+package vpxgo
+
+import "image"
+
+// RGBToYUV420 converts an RGB image into tightly packed I420 planes (Y, U, V)
+// of width x height. Odd sizes are supported: the chroma planes are
+// (width+1)/2 x (height+1)/2 as in NewI420Frame.
+//
+// RGBからYUV420に変換
+func RGBToYUV420(img image.Image, width, height int) [3][]byte {
+	bounds := img.Bounds()
+	cw, ch := (width+1)/2, (height+1)/2
+	yData := make([]byte, width*height)
+	uData := make([]byte, cw*ch)
+	vData := make([]byte, cw*ch)
+
+	w, h := min(bounds.Dx(), width), min(bounds.Dy(), height)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(b>>8)
+
+			// RGB to YUV変換
+			Y := uint8((66*int(r8)+129*int(g8)+25*int(b8)+128)>>8 + 16)
+			U := uint8((-38*int(r8)-74*int(g8)+112*int(b8)+128)>>8 + 128)
+			V := uint8((112*int(r8)-94*int(g8)-18*int(b8)+128)>>8 + 128)
+
+			yData[y*width+x] = Y
+
+			// UV は 2x2 サブサンプリング
+			if y%2 == 0 && x%2 == 0 {
+				uvIndex := (y/2)*cw + (x / 2)
+				uData[uvIndex] = U
+				vData[uvIndex] = V
+			}
+		}
+	}
+
+	return [3][]byte{yData, uData, vData}
+}
+
+// I420ToNRGBA converts an I420 frame (BT.601, limited range) to a
+// non-premultiplied RGBA image. alpha is a width*height plane; nil means
+// fully opaque.
+func I420ToNRGBA(f *I420Frame, alpha []byte) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, f.Width, f.Height))
+	cw := f.ChromaWidth()
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			c := 298 * (int(f.Y[y*f.Width+x]) - 16)
+			d := int(f.U[(y/2)*cw+x/2]) - 128
+			e := int(f.V[(y/2)*cw+x/2]) - 128
+
+			i := y*img.Stride + x*4
+			img.Pix[i] = clip8((c + 409*e + 128) >> 8)
+			img.Pix[i+1] = clip8((c - 100*d - 208*e + 128) >> 8)
+			img.Pix[i+2] = clip8((c + 516*d + 128) >> 8)
+			img.Pix[i+3] = 255
+			if alpha != nil {
+				img.Pix[i+3] = alpha[y*f.Width+x]
+			}
+		}
+	}
+	return img
+}
+
+func clip8(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
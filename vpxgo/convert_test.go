@@ -0,0 +1,53 @@
+package vpxgo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRGBToYUV420OddSizes(t *testing.T) {
+	for _, size := range []image.Point{{5, 5}, {4, 3}, {1, 1}, {7, 2}} {
+		t.Run(fmt.Sprintf("%dx%d", size.X, size.Y), func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					img.Set(x, y, color.RGBA{R: 255, A: 255})
+				}
+			}
+			planes := RGBToYUV420(img, size.X, size.Y)
+			f := NewI420Frame(size.X, size.Y)
+			for i, want := range []int{len(f.Y), len(f.U), len(f.V)} {
+				if len(planes[i]) != want {
+					t.Fatalf("プレーン %d の長さ %d, want %d", i, len(planes[i]), want)
+				}
+			}
+			// 右端・下端のクロマも埋まっている (赤の V は 240)
+			if v := planes[2][len(planes[2])-1]; v != 240 {
+				t.Errorf("最後の V = %d, want 240", v)
+			}
+		})
+	}
+}
+
+func TestI420ToNRGBARoundtrip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+	planes := RGBToYUV420(img, 3, 3)
+	f := &I420Frame{Width: 3, Height: 3, Y: planes[0], U: planes[1], V: planes[2]}
+	out := I420ToNRGBA(f, nil)
+	for i, v := range out.Pix {
+		if i%4 == 3 {
+			if v != 255 {
+				t.Fatalf("アルファ %d, want 255", v)
+			}
+			continue
+		}
+		if v < 126 || v > 130 {
+			t.Fatalf("Pix[%d] = %d, want 128 付近", i, v)
+		}
+	}
+}
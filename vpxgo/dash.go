@@ -0,0 +1,327 @@
+package vpxgo
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DASHConfig configures a DASHSegmenter.
+type DASHConfig struct {
+	// SegmentDuration は目標のセグメント長。セグメントはキーフレームでのみ区切られる。
+	SegmentDuration time.Duration
+	// WindowSize はマニフェストに載せて保持するセグメント数
+	WindowSize int
+	// Dir が空でなければ init.webm, seg-N.webm, manifest.mpd をこのディレクトリに書き出す
+	Dir string
+}
+
+// DefaultDASHConfig returns 4 second segments with a window of 5 segments.
+func DefaultDASHConfig() DASHConfig {
+	return DASHConfig{SegmentDuration: 4 * time.Second, WindowSize: 5}
+}
+
+// dashSegment は完成した 1 つのメディアセグメントです。
+type dashSegment struct {
+	number  int
+	startMs int64
+	durMs   int64
+	data    []byte
+}
+
+// DASHSegmenter cuts an encoded stream into keyframe-aligned WebM media
+// segments plus an initialisation segment, and maintains a dynamic MPEG-DASH
+// manifest (SegmentTimeline) over a sliding window. Segments can be written
+// to a directory and/or served over HTTP via ServeHTTP.
+type DASHSegmenter struct {
+	cfg     DASHConfig
+	codec   Codec
+	width   int
+	height  int
+	fps     int
+	bitrate int // bps
+
+	// RequestKeyframe is called when a segment reaches its target duration
+	// without a keyframe, typically Encoder.RequestKeyframe.
+	RequestKeyframe func()
+
+	mu        sync.Mutex
+	ww        *WebMWriter
+	buf       bytes.Buffer
+	init      []byte
+	segments  []dashSegment
+	next      int   // 次に完成するセグメント番号
+	curStart  int64 // 作成中セグメントの開始時刻 (ms)、-1 なら未開始
+	requested bool
+	started   time.Time
+	closed    bool
+}
+
+// NewDASHSegmenter prepares the init segment for a stream with the given
+// codec, size, frame rate and nominal bitrate.
+func NewDASHSegmenter(cfg DASHConfig, codec Codec, width, height, fps, bitrateKbps int) (*DASHSegmenter, error) {
+	if cfg.SegmentDuration <= 0 || cfg.WindowSize <= 0 {
+		return nil, fmt.Errorf("セグメント長とウィンドウサイズは正の値で指定してください")
+	}
+	s := &DASHSegmenter{
+		cfg:      cfg,
+		codec:    codec,
+		width:    width,
+		height:   height,
+		fps:      fps,
+		bitrate:  bitrateKbps * 1000,
+		next:     1,
+		curStart: -1,
+		started:  time.Now(),
+	}
+	// シークできない Writer なので Segment はサイズ未確定になり、
+	// init とメディアセグメントを連結すれば 1 本の WebM になる
+	ww, err := NewWebMWriter(&s.buf, codec, width, height, fps)
+	if err != nil {
+		return nil, err
+	}
+	s.ww = ww
+	s.init = append([]byte(nil), s.buf.Bytes()...)
+	s.buf.Reset()
+
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(filepath.Join(cfg.Dir, "init.webm"), s.init); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// WritePacket appends one packet. When the current segment has reached the
+// target duration, the next keyframe closes it and starts a new one.
+func (s *DASHSegmenter) WritePacket(p Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("DASH セグメンターは終了しています")
+	}
+
+	ms := s.ww.ptsMs(p.PTS)
+	if s.curStart < 0 {
+		if !p.Keyframe {
+			return nil // 最初のキーフレームまで捨てる
+		}
+		s.curStart = ms
+	} else if ms-s.curStart >= s.cfg.SegmentDuration.Milliseconds() {
+		if p.Keyframe {
+			if err := s.finishSegment(ms); err != nil {
+				return err
+			}
+			s.curStart = ms
+		} else if !s.requested && s.RequestKeyframe != nil {
+			s.RequestKeyframe()
+			s.requested = true
+		}
+	}
+	return s.ww.WritePacket(p)
+}
+
+// Close finishes the last segment and rewrites the manifest as a static
+// presentation. It does not remove files.
+func (s *DASHSegmenter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.curStart >= 0 && s.buf.Len() > 0 {
+		if err := s.finishSegment(s.ww.lastMs); err != nil {
+			return err
+		}
+	}
+	return s.writeManifest()
+}
+
+// finishSegment は作成中のセグメントを endMs で確定し、ウィンドウ外のセグメントを捨てます。
+func (s *DASHSegmenter) finishSegment(endMs int64) error {
+	data := append([]byte(nil), s.buf.Bytes()...)
+	s.buf.Reset()
+	s.requested = false
+	if err := fixClusterSizes(data); err != nil {
+		return err
+	}
+	seg := dashSegment{number: s.next, startMs: s.curStart, durMs: endMs - s.curStart, data: data}
+	s.next++
+	s.segments = append(s.segments, seg)
+
+	var expired []dashSegment
+	if n := len(s.segments) - s.cfg.WindowSize; n > 0 {
+		expired = append(expired, s.segments[:n]...)
+		s.segments = append([]dashSegment(nil), s.segments[n:]...)
+	}
+	if s.cfg.Dir == "" {
+		return nil
+	}
+	if err := writeFileAtomic(filepath.Join(s.cfg.Dir, dashSegmentName(seg.number)), data); err != nil {
+		return err
+	}
+	if err := s.writeManifest(); err != nil {
+		return err
+	}
+	// マニフェスト更新後に古いセグメントを消す
+	for _, e := range expired {
+		os.Remove(filepath.Join(s.cfg.Dir, dashSegmentName(e.number)))
+	}
+	return nil
+}
+
+func (s *DASHSegmenter) writeManifest() error {
+	if s.cfg.Dir == "" {
+		return nil
+	}
+	return writeFileAtomic(filepath.Join(s.cfg.Dir, "manifest.mpd"), s.manifest())
+}
+
+// Manifest returns the current MPD document.
+func (s *DASHSegmenter) Manifest() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.manifest()
+}
+
+func (s *DASHSegmenter) manifest() []byte {
+	codecs := "vp8"
+	if s.codec == CodecVP9 {
+		codecs = "vp9"
+	}
+	segSec := s.cfg.SegmentDuration.Seconds()
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	if s.closed {
+		var total int64
+		if n := len(s.segments); n > 0 {
+			total = s.segments[n-1].startMs + s.segments[n-1].durMs
+		}
+		fmt.Fprintf(&b, `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="static" mediaPresentationDuration="%s" minBufferTime="PT%.1fS">`+"\n",
+			dashDuration(time.Duration(total)*time.Millisecond), segSec)
+	} else {
+		fmt.Fprintf(&b, `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="dynamic" availabilityStartTime="%s" publishTime="%s" minimumUpdatePeriod="PT%.1fS" timeShiftBufferDepth="PT%.1fS" suggestedPresentationDelay="PT%.1fS" minBufferTime="PT%.1fS">`+"\n",
+			s.started.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339),
+			segSec, segSec*float64(s.cfg.WindowSize), segSec*2, segSec)
+	}
+	b.WriteString(`  <Period id="0" start="PT0S">` + "\n")
+	b.WriteString(`    <AdaptationSet mimeType="video/webm" segmentAlignment="true" startWithSAP="1">` + "\n")
+	fmt.Fprintf(&b, `      <Representation id="0" codecs="%s" width="%d" height="%d" frameRate="%d" bandwidth="%d">`+"\n",
+		codecs, s.width, s.height, s.fps, s.bitrate)
+	startNumber := s.next
+	if len(s.segments) > 0 {
+		startNumber = s.segments[0].number
+	}
+	fmt.Fprintf(&b, `        <SegmentTemplate timescale="1000" initialization="init.webm" media="seg-$Number$.webm" startNumber="%d">`+"\n", startNumber)
+	b.WriteString("          <SegmentTimeline>\n")
+	for _, seg := range s.segments {
+		fmt.Fprintf(&b, `            <S t="%d" d="%d"/>`+"\n", seg.startMs, seg.durMs)
+	}
+	b.WriteString("          </SegmentTimeline>\n")
+	b.WriteString("        </SegmentTemplate>\n")
+	b.WriteString("      </Representation>\n")
+	b.WriteString("    </AdaptationSet>\n")
+	b.WriteString("  </Period>\n")
+	b.WriteString("</MPD>\n")
+	return []byte(b.String())
+}
+
+// ServeHTTP serves manifest.mpd, init.webm and seg-N.webm from memory. Mount
+// it with http.StripPrefix under any path.
+func (s *DASHSegmenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	name := path.Base(r.URL.Path)
+	switch {
+	case name == "manifest.mpd":
+		w.Header().Set("Content-Type", "application/dash+xml")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(s.Manifest())
+		return
+	case name == "init.webm":
+		w.Header().Set("Content-Type", "video/webm")
+		w.Write(s.init)
+		return
+	case strings.HasPrefix(name, "seg-") && strings.HasSuffix(name, ".webm"):
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "seg-"), ".webm"))
+		if err != nil {
+			break
+		}
+		s.mu.Lock()
+		var data []byte
+		for _, seg := range s.segments {
+			if seg.number == n {
+				data = seg.data
+			}
+		}
+		s.mu.Unlock()
+		if data == nil {
+			break
+		}
+		w.Header().Set("Content-Type", "video/webm")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Write(data)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func dashSegmentName(n int) string {
+	return fmt.Sprintf("seg-%d.webm", n)
+}
+
+// dashDuration は xs:duration 形式 (PTnn.nnnS) に変換します。
+func dashDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%.3fS", d.Seconds())
+}
+
+// fixClusterSizes はサイズ未確定で書かれたクラスタのサイズ欄を実際のサイズで埋めます。
+// セグメント単体でも扱えるように、セグメント内のクラスタはすべて確定サイズにする。
+func fixClusterSizes(data []byte) error {
+	clusterID := ebmlID(ebmlIDCluster)
+	hdr := len(clusterID) + len(ebmlUnknownSizeBytes)
+	pos := 0
+	for pos < len(data) {
+		if len(data)-pos < hdr || !bytes.Equal(data[pos:pos+len(clusterID)], clusterID) {
+			return fmt.Errorf("セグメントがクラスタで始まっていません")
+		}
+		child := pos + hdr
+		for child < len(data) && !bytes.HasPrefix(data[child:], clusterID) {
+			idLen := ebmlLength(data[child])
+			if idLen == 0 || child+idLen > len(data) {
+				return fmt.Errorf("不正なクラスタ要素です")
+			}
+			size, n := ebmlVint(data[child+idLen:])
+			if n == 0 {
+				return fmt.Errorf("不正なクラスタ要素です")
+			}
+			child += idLen + n + int(size)
+		}
+		if child > len(data) {
+			return fmt.Errorf("クラスタ要素がセグメント外にはみ出しています")
+		}
+		copy(data[pos+len(clusterID):], ebmlSize8(uint64(child-pos-hdr)))
+		pos = child
+	}
+	return nil
+}
+
+// writeFileAtomic は一時ファイルに書いてから置き換えるので、読み手が途中の内容を見ることはありません。
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,124 @@
+package vpxgo
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dashTestSegment はサイズ未確定のクラスタ 2 つ (キーフレームごと) からなるセグメントと init を返します。
+func dashTestSegment(t *testing.T) (init, seg []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	ww, err := NewWebMWriter(&buf, CodecVP8, 64, 48, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	init = append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	for _, p := range webmTestPackets(20) {
+		if err := ww.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return init, append([]byte(nil), buf.Bytes()...)
+}
+
+func TestFixClusterSizes(t *testing.T) {
+	init, seg := dashTestSegment(t)
+	if err := fixClusterSizes(seg); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 つ目のクラスタの位置が 1 つ目のサイズから求まる
+	clusterID := ebmlID(ebmlIDCluster)
+	hdr := len(clusterID) + 8
+	size, n := ebmlVint(seg[len(clusterID):])
+	if n != 8 {
+		t.Fatalf("サイズ欄 %d バイト", n)
+	}
+	second := hdr + int(size)
+	if !bytes.HasPrefix(seg[second:], clusterID) {
+		t.Fatalf("1 つ目のクラスタのサイズ %d が次のクラスタを指していません", size)
+	}
+	size, _ = ebmlVint(seg[second+len(clusterID):])
+	if second+hdr+int(size) != len(seg) {
+		t.Errorf("2 つ目のクラスタのサイズ %d がセグメントの終わりと一致しません", size)
+	}
+
+	// init と連結すれば 1 本の WebM として読める
+	_, got := readWebMPackets(t, append(init, seg...))
+	if len(got) != 20 {
+		t.Errorf("%d パケット, want 20", len(got))
+	}
+}
+
+func TestFixClusterSizesRejectsMalformed(t *testing.T) {
+	_, seg := dashTestSegment(t)
+	for name, data := range map[string][]byte{
+		"no cluster": seg[1:],
+		"truncated":  seg[:len(seg)-2],
+		"short id":   append(append([]byte(nil), seg...), 0x1a),
+	} {
+		if err := fixClusterSizes(data); err == nil {
+			t.Errorf("%s: エラーになりません", name)
+		}
+	}
+}
+
+func TestDASHSegmenterWindow(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDASHSegmenter(DASHConfig{SegmentDuration: time.Second, WindowSize: 2, Dir: dir}, CodecVP8, 64, 48, 10, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requested := 0
+	s.RequestKeyframe = func() { requested++ }
+	// 1.5 秒ごとのキーフレームなので、各セグメントは 1 秒を過ぎてから次のキーフレームで切れる
+	for i := 0; i < 60; i++ {
+		kf := i%15 == 0
+		b0 := byte(1)
+		if kf {
+			b0 = 0
+		}
+		if err := s.WritePacket(Packet{Data: []byte{b0, byte(i)}, PTS: int64(i), Duration: 1, Keyframe: kf}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if requested != 4 {
+		t.Errorf("キーフレーム要求 %d 回, want 4", requested)
+	}
+
+	// 4 セグメントのうちウィンドウ内の最後の 2 つだけが残る
+	for n := 1; n <= 4; n++ {
+		_, err := os.Stat(filepath.Join(dir, dashSegmentName(n)))
+		if exists := err == nil; exists != (n >= 3) {
+			t.Errorf("%s: exists=%v", dashSegmentName(n), exists)
+		}
+	}
+	mpd := string(s.Manifest())
+	for _, want := range []string{`type="static"`, `startNumber="3"`, `<S t="3000" d="1500"/>`, `<S t="4500" d="1500"/>`, `mediaPresentationDuration="PT6.000S"`} {
+		if !strings.Contains(mpd, want) {
+			t.Errorf("マニフェストに %s がありません:\n%s", want, mpd)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/dash/seg-4.webm", nil))
+	data, _ := os.ReadFile(filepath.Join(dir, dashSegmentName(4)))
+	if rec.Code != 200 || !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Errorf("seg-4.webm の配信: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/dash/seg-1.webm", nil))
+	if rec.Code != 404 {
+		t.Errorf("ウィンドウ外の seg-1.webm: %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package vpxgo
+
+import (
+	"fmt"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+func (c Codec) decoderIface() *vpx.CodecIface {
+	if c == CodecVP9 {
+		return vpx.DecoderIfaceVP9()
+	}
+	return vpx.DecoderIfaceVP8()
+}
+
+// Decoder decodes VP8/VP9 frames into I420 pictures.
+type Decoder struct {
+	ctx   *vpx.CodecCtx
+	codec Codec
+}
+
+// NewDecoder initialises a decoder for the given codec.
+func NewDecoder(codec Codec) (*Decoder, error) {
+	ctx := vpx.NewCodecCtx()
+	if ctx == nil {
+		return nil, fmt.Errorf("vpx.NewCodecCtx() returned nil")
+	}
+	if res := vpx.CodecDecInitVer(ctx, codec.decoderIface(), nil, 0, vpx.DecoderABIVersion); res != vpx.CodecOk {
+		return nil, newCodecError(ctx, codec.String()+"デコーダー初期化", res)
+	}
+	return &Decoder{ctx: ctx, codec: codec}, nil
+}
+
+// Decode decodes one compressed frame and returns the pictures it produced.
+// VP9 superframes may yield zero or one shown frame.
+func (d *Decoder) Decode(data []byte) ([]*I420Frame, error) {
+	var frames []*I420Frame
+	err := d.decode(data, func(img *vpx.Image) error {
+		f, err := i420FromImage(img)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, f)
+		return nil
+	})
+	return frames, err
+}
+
+// Decode16 decodes one compressed frame like Decode but keeps the full
+// sample depth and chroma format, so 10/12-bit and 4:4:4 VP9 streams can be
+// compared bit-exactly with their source.
+func (d *Decoder) Decode16(data []byte) ([]*Frame16, error) {
+	var frames []*Frame16
+	err := d.decode(data, func(img *vpx.Image) error {
+		f, err := frame16FromImage(img)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, f)
+		return nil
+	})
+	return frames, err
+}
+
+// decode は data をデコードし、出力された画像ごとに fn を呼びます。
+func (d *Decoder) decode(data []byte, fn func(img *vpx.Image) error) error {
+	if res := vpx.CodecDecode(d.ctx, string(data), uint32(len(data)), nil, 0); res != vpx.CodecOk {
+		return newCodecError(d.ctx, d.codec.String()+"デコード", res)
+	}
+
+	var iter vpx.CodecIter
+	for {
+		img := vpx.CodecGetFrame(d.ctx, &iter)
+		if img == nil {
+			return nil
+		}
+		img.Deref()
+		if err := fn(img); err != nil {
+			return err
+		}
+	}
+}
+
+// Close releases the decoder.
+func (d *Decoder) Close() {
+	if d.ctx != nil {
+		vpx.CodecDestroy(d.ctx)
+	}
+}
@@ -0,0 +1,34 @@
+// Package vpxgo is a VP8/VP9 media toolkit built on libvpx.
+//
+// It provides:
+//
+//   - Encoder: VP8/VP9 encoding from gocv Mats or I420 frames, with runtime
+//     bitrate and codec controls, temporal layers (VP8), spatial/temporal SVC
+//     (VP9), loss recovery, ROI/active maps, two-pass VBR and per-frame
+//     statistics (see EncoderConfig and CodecControls).
+//   - Decoder: VP8/VP9 decoding into I420Frame.
+//   - Conversion: RGBToYUV420, MatToI420 and ScaleI420.
+//   - Containers: IVF and WebM readers and writers (OpenPacketReader,
+//     CreatePacketWriter) and a Y4M reader.
+//   - RTP: the VP8 payload descriptor (VP8Payloader) and simulcast groups.
+//   - Quality tools: CompareQuality and RunSweep, and Prometheus metrics.
+//
+// A minimal webcam encoder:
+//
+//	enc, err := vpxgo.NewEncoder(vpxgo.DefaultEncoderConfig(640, 480))
+//	if err != nil {
+//		return err
+//	}
+//	defer enc.Close()
+//	for {
+//		// mat は gocv.VideoCapture から読み込んだ BGR フレーム
+//		pkts, err := enc.EncodeFrame(mat, 0)
+//		...
+//	}
+//
+// Failures of libvpx calls are returned as *CodecError, which unwraps to the
+// vpx.ErrCodec* sentinels of github.com/xlab/libvpx-go/vpx.
+//
+// The package needs libvpx and OpenCV (through gocv) at build time. The
+// lower level libvpx controls it relies on live in libvpxGo/vpxext.
+package vpxgo
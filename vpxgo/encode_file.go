@@ -0,0 +1,218 @@
+package vpxgo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// FileEncodeOptions controls EncodeFile.
+type FileEncodeOptions struct {
+	Codec       Codec
+	BitrateKbps int
+	CpuUsed     int
+	MaxFrames   int // 0 ならクリップ全体
+	// Config はプリセットやプロファイルから解決した設定で、指定すると Codec, BitrateKbps,
+	// CpuUsed の代わりに使う。解像度とフレームレートはソースに合わせて上書きする。
+	Config *EncoderConfig
+
+	// Passes は 1 または 2。Pass で片方のパスだけを実行でき (0 なら両方)、
+	// その場合 1 パス目の統計は StatsPath に書き出し/読み込みする。
+	Passes    int
+	Pass      int
+	StatsPath string
+
+	// SceneCut は場面転換を検出してキーフレームを挿入する。固定間隔のキーフレームは
+	// 10 秒ごとに減らし、カット同士は SceneMinSpacing フレーム以上空ける (0 なら 1 秒)。
+	SceneCut        bool
+	SceneThreshold  float64
+	SceneMinSpacing int
+	// OnSceneCut は検出したカットごとに呼ばれる (2 パスでは最後のパスのみ)
+	OnSceneCut func(SceneCut)
+}
+
+// EncodeFile encodes the Y4M or video file at srcPath into an IVF or WebM
+// file. With two passes the first pass only analyses the clip and the second
+// pass uses its statistics to distribute bits (VBR) for the best quality at
+// the target size.
+func EncodeFile(srcPath, outPath string, opts FileEncodeOptions) error {
+	if opts.Passes != 1 && opts.Passes != 2 {
+		return fmt.Errorf("パス数は 1 または 2 で指定してください: %d", opts.Passes)
+	}
+	if opts.Pass != 0 && (opts.Passes != 2 || opts.Pass > 2 || opts.StatsPath == "") {
+		return fmt.Errorf("パスを個別に実行するには 2 パス指定と統計ファイルが必要です")
+	}
+
+	if opts.Passes == 1 {
+		_, err := encodePass(srcPath, outPath, opts, 0, nil)
+		return err
+	}
+
+	var stats []byte
+	if opts.Pass != 2 {
+		var err error
+		if stats, err = encodePass(srcPath, "", opts, 1, nil); err != nil {
+			return err
+		}
+		if opts.StatsPath != "" {
+			if err := os.WriteFile(opts.StatsPath, stats, 0o644); err != nil {
+				return fmt.Errorf("統計ファイル書き込みエラー: %v", err)
+			}
+		}
+		if opts.Pass == 1 {
+			return nil
+		}
+	} else {
+		var err error
+		if stats, err = os.ReadFile(opts.StatsPath); err != nil {
+			return fmt.Errorf("統計ファイル読み込みエラー: %v", err)
+		}
+	}
+	_, err := encodePass(srcPath, outPath, opts, 2, stats)
+	return err
+}
+
+// encodePass はソースを 1 回エンコードし、1 パス目であれば統計データを返します。
+func encodePass(srcPath, outPath string, opts FileEncodeOptions, pass int, stats []byte) ([]byte, error) {
+	src, srcCloser, err := OpenFrameSource(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer srcCloser.Close()
+
+	var create packetWriterFactory
+	if outPath != "" {
+		create = func(codec Codec, width, height int, fps float64) (PacketWriter, io.Closer, error) {
+			return CreatePacketWriter(outPath, codec, width, height, fps)
+		}
+	}
+	return encodeSource(src, create, opts, pass, stats, os.Stdout)
+}
+
+// packetWriterFactory は最初のフレームのサイズが分かってから出力先を作成します。
+// fps はソースのフレームレート (29.97 などの非整数もそのまま渡す)。
+// PacketWriter を閉じた後に io.Closer を閉じる。
+type packetWriterFactory func(codec Codec, width, height int, fps float64) (PacketWriter, io.Closer, error)
+
+// fileEncoderConfig はファイル変換用のエンコーダー設定を作ります。base があればそれを
+// 基にし、なければ個別のオプションから作る。
+func fileEncoderConfig(base *EncoderConfig, codec Codec, kbps, cpuUsed, width, height int, fps float64) EncoderConfig {
+	cfg := DefaultEncoderConfig(width, height)
+	if base != nil {
+		cfg = *base
+		cfg.Width, cfg.Height = width, height
+	} else {
+		cfg.Codec = codec
+		cfg.BitrateKbps = kbps
+		cfg.Controls.CpuUsed = &cpuUsed
+	}
+	cfg.FPS = int(fps + 0.5)
+	return cfg
+}
+
+// encodeSource は src の全フレームをエンコードします。create が nil なら出力せず、
+// 進捗は logw に書く。
+func encodeSource(src FrameSource, create packetWriterFactory, opts FileEncodeOptions, pass int, stats []byte, logw io.Writer) ([]byte, error) {
+	frame, err := src.ReadFrame()
+	if err != nil {
+		return nil, fmt.Errorf("ソースフレーム読み込みエラー: %v", err)
+	}
+	fps := src.FPS()
+	if fps <= 0 {
+		fps = 30
+	}
+
+	cfg := fileEncoderConfig(opts.Config, opts.Codec, opts.BitrateKbps, opts.CpuUsed, frame.Width, frame.Height, fps)
+	cfg.Pass = pass
+	cfg.TwoPassStats = stats
+	var scenes *SceneDetector
+	if opts.SceneCut {
+		scenes = NewSceneDetector(SceneDetectorConfig{Threshold: opts.SceneThreshold, MinSpacing: opts.SceneMinSpacing, FPS: fps})
+		if cfg.KeyframeMaxDist == 0 {
+			cfg.KeyframeMaxDist = cfg.FPS * 10
+		}
+	}
+	enc, err := NewEncoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+
+	var out PacketWriter
+	if create != nil {
+		pw, f, err := create(cfg.Codec, frame.Width, frame.Height, fps)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		out = pw
+	}
+	write := func(pkts []Packet) error {
+		if out == nil {
+			return nil
+		}
+		for _, p := range pkts {
+			if err := out.WritePacket(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	label := "1 パス"
+	if pass > 0 {
+		label = fmt.Sprintf("パス %d/2", pass)
+	}
+	start := time.Now()
+	frames := 0
+	for frame != nil {
+		var flags vpx.EncFrameFlags
+		if scenes != nil && scenes.Check(frame) {
+			// 2 パスでは両方のパスで同じフレームをキーフレームにする
+			flags |= vpx.EflagForceKf
+			if opts.OnSceneCut != nil && pass != 1 {
+				cuts := scenes.Cuts()
+				opts.OnSceneCut(cuts[len(cuts)-1])
+			}
+		}
+		pkts, err := enc.EncodeI420(frame, flags)
+		if err != nil {
+			return nil, err
+		}
+		if err := write(pkts); err != nil {
+			return nil, err
+		}
+		frames++
+		if frames%100 == 0 {
+			fmt.Fprintf(logw, "%s: %d フレーム\n", label, frames)
+		}
+
+		if opts.MaxFrames > 0 && frames >= opts.MaxFrames {
+			break
+		}
+		if frame, err = src.ReadFrame(); err == io.EOF {
+			frame = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	pkts, err := enc.Flush()
+	if err != nil {
+		return nil, err
+	}
+	if err := write(pkts); err != nil {
+		return nil, err
+	}
+	if out != nil {
+		if err := out.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	st := enc.Stats()
+	fmt.Fprintf(logw, "%s完了: %d フレーム, %d bytes, %.1f fps\n", label, frames, st.Bytes, float64(frames)/time.Since(start).Seconds())
+	return enc.FirstPassStats(), nil
+}
@@ -0,0 +1,783 @@
+package vpxgo
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+	"gocv.io/x/gocv"
+)
+
+// Codec selects the libvpx encoder implementation.
+type Codec int
+
+const (
+	CodecVP8 Codec = iota
+	CodecVP9
+)
+
+func (c Codec) String() string {
+	if c == CodecVP9 {
+		return "VP9"
+	}
+	return "VP8"
+}
+
+func (c Codec) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Codec) UnmarshalText(b []byte) error {
+	v, err := ParseCodec(string(b))
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// ParseCodec parses "vp8" or "vp9" (case-insensitive).
+func ParseCodec(s string) (Codec, error) {
+	switch strings.ToLower(s) {
+	case "vp8":
+		return CodecVP8, nil
+	case "vp9":
+		return CodecVP9, nil
+	}
+	return 0, fmt.Errorf("未対応のコーデック: %q (vp8 または vp9)", s)
+}
+
+func (c Codec) encoderIface() *vpx.CodecIface {
+	if c == CodecVP9 {
+		return vpx.EncoderIfaceVP9()
+	}
+	return vpx.EncoderIfaceVP8()
+}
+
+// EncoderConfig holds the parameters used to initialise an Encoder.
+type EncoderConfig struct {
+	Codec       Codec `json:"codec"`
+	Width       int   `json:"width"`
+	Height      int   `json:"height"`
+	FPS         int   `json:"fps"`
+	BitrateKbps int   `json:"bitrate_kbps"`
+	// KeyframeMaxDist は自動キーフレームの最大間隔 (フレーム数、0 なら libvpx の既定値)
+	KeyframeMaxDist int `json:"keyframe_max_dist"`
+
+	// ErrorResilient はフレーム間のエントロピー文脈の引き継ぎを止め、
+	// パケットロス後も後続フレームを復号できるようにする (g_error_resilient)。
+	ErrorResilient bool `json:"error_resilient"`
+	// TokenPartitions は DCT トークンを 2^n 個のパーティションに分割する (0..3, VP8 のみ)。
+	TokenPartitions int `json:"token_partitions"`
+
+	// TemporalLayers は VP8 の時間スケーラビリティ設定 (Layers が 0 なら無効)
+	TemporalLayers TemporalLayerConfig `json:"temporal_layers"`
+	// SVC は VP9 の空間/時間スケーラビリティ設定 (SpatialLayers が 0 なら無効)
+	SVC SVCConfig `json:"svc"`
+
+	// Controls は cpu-used などのコーデック制御 (未指定の項目は libvpx の既定値)
+	Controls CodecControls `json:"controls"`
+
+	// Lossless は VP9 の可逆符号化を有効にする (ビットレート設定は無視される)
+	Lossless bool `json:"lossless"`
+	// BitDepth は VP9 の符号化ビット深度 (0 または 8, 10, 12)。10/12 はプロファイル 2/3。
+	BitDepth int `json:"bit_depth"`
+	// Chroma444 は VP9 を 4:4:4 (プロファイル 1/3) で符号化する
+	Chroma444 bool `json:"chroma_444"`
+
+	// Pass は 2 パスエンコードで実行するパス (0: 1 パス, 1: 解析パス, 2: 本エンコード)。
+	// 2 パスはファイル向けの VBR で、リアルタイム用途には使えない。
+	Pass int `json:"pass"`
+	// TwoPassStats は 1 パス目の Encoder.FirstPassStats の結果 (Pass が 2 のとき必須)
+	TwoPassStats []byte `json:"-"`
+	// LagInFrames は 1 パスでも画質優先モードで先読みするフレーム数 (0..25、0 ならリアルタイム)。
+	// AutoAltRef と ARNR は先読みがないと効かない。2 パスでは常に 25 フレーム先読みする。
+	LagInFrames int `json:"lag_in_frames"`
+
+	// EnablePSNR は VPX_CODEC_USE_PSNR を指定してフレームごとの PSNR を集計する
+	EnablePSNR bool `json:"enable_psnr"`
+	// EnableSSIM は再構成画像 (プレビューフレーム) と入力の輝度 SSIM を計算する
+	EnableSSIM bool `json:"enable_ssim"`
+}
+
+// DefaultEncoderConfig returns the settings NewVP8Encoder has always used.
+func DefaultEncoderConfig(width, height int) EncoderConfig {
+	return EncoderConfig{
+		Codec:       CodecVP8,
+		Width:       width,
+		Height:      height,
+		FPS:         30,
+		BitrateKbps: 1000,
+	}
+}
+
+// Validate reports the first invalid or conflicting setting in c.
+func (c EncoderConfig) Validate() error {
+	if c.Width <= 0 || c.Height <= 0 {
+		return fmt.Errorf("解像度が不正です: %dx%d", c.Width, c.Height)
+	}
+	if c.FPS <= 0 {
+		return fmt.Errorf("FPS は 1 以上で指定してください: %d", c.FPS)
+	}
+	if c.BitrateKbps <= 0 {
+		return fmt.Errorf("ビットレートは 1 kbps 以上で指定してください: %d", c.BitrateKbps)
+	}
+	if c.TokenPartitions < 0 || c.TokenPartitions > 3 {
+		return fmt.Errorf("TokenPartitions は 0..3 で指定してください: %d", c.TokenPartitions)
+	}
+	if c.Codec != CodecVP8 && (c.TokenPartitions > 0 || c.TemporalLayers.Layers > 0) {
+		return fmt.Errorf("TokenPartitions と TemporalLayers は VP8 専用です (VP9 は SVC を使用)")
+	}
+	if err := c.Controls.validate(c.Codec); err != nil {
+		return err
+	}
+	if c.Pass < 0 || c.Pass > 2 {
+		return fmt.Errorf("Pass は 0..2 で指定してください: %d", c.Pass)
+	}
+	if c.Pass == 2 && len(c.TwoPassStats) == 0 {
+		return fmt.Errorf("2 パス目には 1 パス目の統計 (TwoPassStats) が必要です")
+	}
+	if c.Pass > 0 && (c.TemporalLayers.Layers > 0 || c.SVC.SpatialLayers > 0) {
+		return fmt.Errorf("2 パスエンコードではスケーラビリティを使用できません")
+	}
+	if c.LagInFrames < 0 || c.LagInFrames > 25 {
+		return fmt.Errorf("LagInFrames は 0..25 で指定してください: %d", c.LagInFrames)
+	}
+	if c.LagInFrames > 0 && (c.TemporalLayers.Layers > 0 || c.SVC.SpatialLayers > 0) {
+		return fmt.Errorf("先読み (LagInFrames) とスケーラビリティは同時に使用できません")
+	}
+	if c.Codec != CodecVP9 && c.SVC.SpatialLayers > 0 {
+		return fmt.Errorf("SVC は VP9 専用です")
+	}
+	if c.KeyframeMaxDist < 0 {
+		return fmt.Errorf("KeyframeMaxDist は 0 以上で指定してください: %d", c.KeyframeMaxDist)
+	}
+	if c.BitDepth != 0 && c.BitDepth != 8 && c.BitDepth != 10 && c.BitDepth != 12 {
+		return fmt.Errorf("BitDepth は 8, 10, 12 のいずれかで指定してください: %d", c.BitDepth)
+	}
+	if c.Codec != CodecVP9 && (c.Lossless || c.BitDepth > 8 || c.Chroma444) {
+		return fmt.Errorf("Lossless, BitDepth, Chroma444 は VP9 専用です")
+	}
+	return nil
+}
+
+// bitDepth は 0 (未指定) を 8 として返します。
+func (c EncoderConfig) bitDepth() int {
+	if c.BitDepth == 0 {
+		return 8
+	}
+	return c.BitDepth
+}
+
+// profile は VP9 のプロファイル番号 (0..3) を返します。
+func (c EncoderConfig) profile() int {
+	p := 0
+	if c.bitDepth() > 8 {
+		p = 2
+	}
+	if c.Chroma444 {
+		p++
+	}
+	return p
+}
+
+// defaultEncCfg returns the codec's default configuration as plain Go values.
+//
+// libvpx-go は C 側の構造体への参照を保持している間 Go 側のフィールド変更を
+// 無視するため、値を読み出した後に参照を解放し、次の呼び出しで再構築させる。
+func defaultEncCfg(iface *vpx.CodecIface) (*vpx.CodecEncCfg, error) {
+	cfg := &vpx.CodecEncCfg{}
+	if res := vpx.CodecEncConfigDefault(iface, cfg, 0); res != vpx.CodecOk {
+		return nil, newCodecError(nil, "既定設定取得", res)
+	}
+	cfg.Deref()
+	tb := cfg.GTimebase
+	tb.Deref()
+	cfg.GTimebase = vpx.Rational{Num: tb.Num, Den: tb.Den}
+	cfg.RcTwopassStatsIn = vpx.FixedBuf{}
+	cfg.RcFirstpassMbStatsIn = vpx.FixedBuf{}
+	cfg.Free()
+	return cfg, nil
+}
+
+// Encoder wraps a libvpx VP8 or VP9 encoder context.
+type Encoder struct {
+	ctx    *vpx.CodecCtx
+	cfg    *vpx.CodecEncCfg
+	codec  Codec
+	width  int
+	height int
+
+	bitDepth  int
+	chroma444 bool
+
+	pts      int64
+	shown    int64 // 次に表示フレームとして出力されるはずの PTS (間引きの検出用)
+	recovery *LossRecovery
+	temporal *temporalLayers
+	svc      bool
+	forceKf  atomic.Bool
+	ssim     bool
+	stats    statsCollector
+	config   EncoderConfig
+
+	// passStats は 1 パス目の統計、statsIn は 2 パス目に libvpx へ渡した C メモリ
+	passStats []byte
+	statsIn   vpx.FixedBuf
+
+	// mu はエンコードと実行時の設定変更を直列化する
+	mu sync.Mutex
+}
+
+// NewVP8Encoder creates a VP8 encoder with DefaultEncoderConfig.
+func NewVP8Encoder(width, height int) (*Encoder, error) {
+	return NewEncoder(DefaultEncoderConfig(width, height))
+}
+
+// NewEncoder validates c and initialises a libvpx encoder with it.
+func NewEncoder(c EncoderConfig) (enc *Encoder, err error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	// エンコーダーを初期化
+	iface := c.Codec.encoderIface()
+	cfg, err := defaultEncCfg(iface)
+	if err != nil {
+		return nil, fmt.Errorf("%sエンコーダー設定初期化失敗1: %w", c.Codec, err)
+	}
+
+	// 設定
+	cfg.GW = uint32(c.Width)                    // 幅 (Width)
+	cfg.GH = uint32(c.Height)                   // 高さ (Height)
+	cfg.GTimebase.Num = 1                       // タイムベースの分子 (例: 1/30秒 = 30fps)
+	cfg.GTimebase.Den = int32(c.FPS)            // タイムベースの分母
+	cfg.RcTargetBitrate = uint32(c.BitrateKbps) // 目標ビットレート (kbps)
+	cfg.GUsage = 1                              // 1 = realtime mode for VP8 (see libvpx documentation)
+	if c.Codec == CodecVP9 {
+		cfg.GProfile = uint32(c.profile())
+		cfg.GBitDepth = vpx.BitDepth(c.bitDepth())
+		cfg.GInputBitDepth = uint32(c.bitDepth())
+	}
+	var temporal *temporalLayers
+	if c.TemporalLayers.Layers > 0 {
+		tl, err := c.TemporalLayers.withDefaults(c.BitrateKbps)
+		if err != nil {
+			return nil, err
+		}
+		tl.apply(cfg)
+		temporal = newTemporalLayers(tl)
+	}
+	var svc SVCConfig
+	if c.SVC.SpatialLayers > 0 {
+		var err error
+		if svc, err = c.SVC.withDefaults(c.BitrateKbps); err != nil {
+			return nil, err
+		}
+		svc.apply(cfg)
+	}
+	if c.LagInFrames > 0 {
+		// 先読みありの画質優先モード (AutoAltRef/ARNR を有効にするため)
+		cfg.GUsage = 0
+		cfg.GLagInFrames = uint32(c.LagInFrames)
+	}
+	var statsIn vpx.FixedBuf
+	switch c.Pass {
+	case 1, 2:
+		// ファイル向け: 画質優先モードで先読みを有効にした VBR
+		cfg.GUsage = 0
+		cfg.GLagInFrames = 25
+		cfg.RcEndUsage = vpx.Vbr
+		cfg.GPass = vpx.RcFirstPass
+		if c.Pass == 2 {
+			cfg.GPass = vpx.RcLastPass
+			statsIn = vpxext.NewFixedBuf(c.TwoPassStats)
+			cfg.RcTwopassStatsIn = statsIn
+			// 初期化に失敗した場合はここで解放し、成功時は Encoder.Close に任せる
+			defer func() {
+				if enc == nil {
+					vpxext.FreeFixedBuf(statsIn)
+				}
+			}()
+		}
+	}
+	if c.KeyframeMaxDist > 0 {
+		cfg.KfMode = vpx.KfAuto
+		cfg.KfMaxDist = uint32(c.KeyframeMaxDist)
+	}
+	if c.ErrorResilient {
+		cfg.GErrorResilient = vpx.ErrorResilientDefault
+		if c.TokenPartitions > 0 {
+			// パーティション単位で独立に復号できるようにする
+			cfg.GErrorResilient |= vpx.ErrorResilientPartitions
+		}
+	}
+
+	// 設定が正しく反映されたか、確認のためにログ出力 (標準出力はパイプのデータに使われることがあるので log を使う)
+	log.Printf("Goエンコーダー設定 (cfg) - 幅: %d, 高さ: %d", cfg.GW, cfg.GH)
+	log.Printf("Goエンコーダー設定 (cfg) - タイムベース (Num/Den): %d/%d", cfg.GTimebase.Num, cfg.GTimebase.Den)
+	log.Printf("Goエンコーダー設定 (cfg) - 目標ビットレート: %d kbps", cfg.RcTargetBitrate)
+	log.Printf("Goエンコーダー設定 (cfg) - Usage: %d", cfg.GUsage)
+
+	ctx := vpx.NewCodecCtx()
+	if ctx == nil {
+		return nil, fmt.Errorf("vpx.NewCodecCtx() returned nil") // ここで処理を中断
+	}
+
+	var initFlags vpx.CodecFlags
+	if c.EnablePSNR {
+		initFlags |= vpx.CodecUsePsnr
+	}
+	if c.bitDepth() > 8 {
+		initFlags |= vpx.CodecUseHighbitdepth
+	}
+	if res := vpx.CodecEncInitVer(ctx, iface, cfg, initFlags, vpx.EncoderABIVersion); res != vpx.CodecOk {
+		return nil, newCodecError(ctx, c.Codec.String()+"エンコーダー初期化", res)
+	}
+
+	if err := c.Controls.apply(ctx, c.Codec); err != nil {
+		vpx.CodecDestroy(ctx)
+		return nil, err
+	}
+	// 以下の失敗時はエラー詳細が CodecDestroy で消えるため、先に CodecError を作ってから解放する
+	if c.Lossless {
+		if res := vpxext.ControlInt(ctx, vpxext.Vp9eSetLossless, 1); res != vpx.CodecOk {
+			err := newCodecError(ctx, "可逆符号化設定", res)
+			vpx.CodecDestroy(ctx)
+			return nil, err
+		}
+	}
+	if c.TokenPartitions > 0 {
+		if res := vpxext.ControlInt(ctx, vpxext.Vp8eSetTokenPartitions, c.TokenPartitions); res != vpx.CodecOk {
+			err := newCodecError(ctx, "トークンパーティション設定", res)
+			vpx.CodecDestroy(ctx)
+			return nil, err
+		}
+	}
+	if svc.SpatialLayers > 0 {
+		if res := vpxext.ControlInt(ctx, vpxext.Vp9eSetSvc, 1); res != vpx.CodecOk {
+			err := newCodecError(ctx, "SVC 有効化", res)
+			vpx.CodecDestroy(ctx)
+			return nil, err
+		}
+		if res := vpxext.SetSVCParameters(ctx, svc.parameters(cfg)); res != vpx.CodecOk {
+			err := newCodecError(ctx, "SVC パラメータ設定", res)
+			vpx.CodecDestroy(ctx)
+			return nil, err
+		}
+	}
+
+	log.Printf("%sエンコーダー初期化成功", c.Codec)
+	return &Encoder{
+		ctx:       ctx,
+		cfg:       cfg,
+		codec:     c.Codec,
+		width:     c.Width,
+		height:    c.Height,
+		bitDepth:  c.bitDepth(),
+		chroma444: c.Chroma444,
+		temporal:  temporal,
+		svc:       svc.SpatialLayers > 0,
+		ssim:      c.EnableSSIM,
+		config:    c,
+		statsIn:   statsIn,
+	}, nil
+}
+
+// SetLossRecovery attaches a loss recovery strategy whose reference flags are
+// applied to every subsequent frame. Pass nil to detach it. It is ignored while
+// temporal layers are enabled, since those patterns own the golden reference.
+// It may be called while another goroutine is encoding.
+func (e *Encoder) SetLossRecovery(r *LossRecovery) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.recovery = r
+}
+
+// RequestKeyframe makes the next encoded frame a keyframe. It may be called from
+// any goroutine, e.g. when an RTCP PLI/FIR arrives.
+func (e *Encoder) RequestKeyframe() {
+	e.forceKf.Store(true)
+}
+
+// SetBitrate changes the target bitrate at runtime. Layer bitrates of temporal
+// or spatial layers are scaled by the same ratio.
+func (e *Encoder) SetBitrate(kbps int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	old := e.cfg.RcTargetBitrate
+	if kbps <= 0 || old == 0 {
+		return fmt.Errorf("不正なビットレート: %d kbps", kbps)
+	}
+	scale := func(v uint32) uint32 { return uint32(uint64(v) * uint64(kbps) / uint64(old)) }
+	for i := range e.cfg.TsTargetBitrate {
+		e.cfg.TsTargetBitrate[i] = scale(e.cfg.TsTargetBitrate[i])
+	}
+	for i := range e.cfg.SsTargetBitrate {
+		e.cfg.SsTargetBitrate[i] = scale(e.cfg.SsTargetBitrate[i])
+	}
+	for i := range e.cfg.LayerTargetBitrate {
+		e.cfg.LayerTargetBitrate[i] = scale(e.cfg.LayerTargetBitrate[i])
+	}
+	e.cfg.RcTargetBitrate = uint32(kbps)
+
+	// Go 側の値から C 側の設定を作り直させる (defaultEncCfg 参照)
+	e.cfg.Free()
+	if res := vpx.CodecEncConfigSet(e.ctx, e.cfg); res != vpx.CodecOk {
+		return newCodecError(e.ctx, "ビットレート変更", res)
+	}
+	return nil
+}
+
+// SetControls changes codec controls at runtime. Only the non-nil fields are
+// applied; the others keep their current value.
+func (e *Encoder) SetControls(c CodecControls) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := c.apply(e.ctx, e.codec); err != nil {
+		return err
+	}
+	e.config.Controls = e.config.Controls.merge(c)
+	return nil
+}
+
+// Stats returns the statistics accumulated so far. It is safe to call while
+// another goroutine is encoding.
+func (e *Encoder) Stats() EncoderStats {
+	return e.stats.snapshot()
+}
+
+// Config returns the configuration the encoder was created with, with the
+// bitrate reflecting runtime changes.
+func (e *Encoder) Config() EncoderConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.config
+	c.BitrateKbps = int(e.cfg.RcTargetBitrate)
+	return c
+}
+
+// SetMetrics reports the statistics of every subsequent frame to m. Pass nil
+// to stop reporting.
+func (e *Encoder) SetMetrics(m *Metrics) {
+	e.stats.setMetrics(m)
+}
+
+// SetStatsLogger writes the statistics of every subsequent frame to l. Pass nil
+// to stop logging.
+func (e *Encoder) SetStatsLogger(l *StatsLogger) {
+	e.stats.setLogger(l)
+}
+
+// Encode encodes one BGR frame and returns the concatenated packet data.
+func (e *Encoder) Encode(mat gocv.Mat) ([]byte, error) {
+	pkts, err := e.EncodeFrame(mat, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded []byte
+	for _, p := range pkts {
+		encoded = append(encoded, p.Data...)
+	}
+	return encoded, nil
+}
+
+// EncodeFrame encodes one frame with the given per-frame flags (vpx.EflagForceKf
+// and the vpxext.Eflag* reference controls) and returns the produced packets.
+// With VP9 SVC enabled, each spatial layer of the superframe is returned as its
+// own packet.
+func (e *Encoder) EncodeFrame(mat gocv.Mat, flags vpx.EncFrameFlags) ([]Packet, error) {
+	start := time.Now()
+
+	// 16 ビットの Mat (CV_16UC3) は ToImage で変換できないので先に分岐する
+	if e.bitDepth > 8 || e.chroma444 {
+		f, err := MatToFrame16(mat, e.bitDepth, e.chroma444)
+		if err != nil {
+			return nil, err
+		}
+		return e.encodeFrame16(f, flags, start)
+	}
+
+	// GoCVのMatからRGBデータを取得
+	img, err := mat.ToImage()
+	if err != nil {
+		return nil, fmt.Errorf("Mat変換エラー: %v", err)
+	}
+
+	// RGBからYUV420に変換
+	yuvData := RGBToYUV420(img, e.width, e.height)
+	return e.encodeYUV(yuvData, flags, start)
+}
+
+// EncodeI420 is like EncodeFrame but takes an already converted I420 frame,
+// e.g. one read from a Y4M file. The frame must match the encoder size.
+func (e *Encoder) EncodeI420(f *I420Frame, flags vpx.EncFrameFlags) ([]Packet, error) {
+	start := time.Now()
+	if f.Width != e.width || f.Height != e.height {
+		return nil, fmt.Errorf("フレームサイズ %dx%d がエンコーダー設定 %dx%d と一致しません", f.Width, f.Height, e.width, e.height)
+	}
+	if e.bitDepth > 8 || e.chroma444 {
+		return e.encodeFrame16(Frame16FromI420(f, e.bitDepth), flags, start)
+	}
+	return e.encodeYUV([3][]byte{f.Y, f.U, f.V}, flags, start)
+}
+
+// EncodeFrame16 encodes a frame whose bit depth and chroma format match the
+// encoder configuration (BitDepth, Chroma444). 8-bit 4:2:0 frames are also
+// accepted by an ordinary encoder.
+func (e *Encoder) EncodeFrame16(f *Frame16, flags vpx.EncFrameFlags) ([]Packet, error) {
+	return e.encodeFrame16(f, flags, time.Now())
+}
+
+func (e *Encoder) encodeFrame16(f *Frame16, flags vpx.EncFrameFlags, start time.Time) ([]Packet, error) {
+	if f.Width != e.width || f.Height != e.height {
+		return nil, fmt.Errorf("フレームサイズ %dx%d がエンコーダー設定 %dx%d と一致しません", f.Width, f.Height, e.width, e.height)
+	}
+	if f.BitDepth != e.bitDepth || f.Chroma444 != e.chroma444 {
+		return nil, fmt.Errorf("フレーム形式 (%d ビット, 4:4:4=%v) がエンコーダー設定 (%d ビット, 4:4:4=%v) と一致しません",
+			f.BitDepth, f.Chroma444, e.bitDepth, e.chroma444)
+	}
+
+	vpxImg := vpx.ImageAlloc(nil, imageFormat16(f.BitDepth, f.Chroma444), uint32(e.width), uint32(e.height), 1)
+	if vpxImg == nil {
+		return nil, fmt.Errorf("vpx image allocation failed")
+	}
+	defer vpx.ImageFree(vpxImg)
+	vpxImg.Deref()
+	if f.BitDepth > 8 {
+		vpxext.SetImageBitDepth(vpxImg, f.BitDepth)
+	}
+	f.copyToImage(vpxImg)
+
+	// SSIM は 8 ビット 4:2:0 の輝度でのみ計算する
+	return e.encodeImage(vpxImg, nil, flags, start)
+}
+
+func (e *Encoder) encodeYUV(yuvData [3][]byte, flags vpx.EncFrameFlags, start time.Time) ([]Packet, error) {
+	// エンコード用のイメージを作成
+	vpxImg := vpx.ImageAlloc(nil, vpx.ImageFormatI420, uint32(e.width), uint32(e.height), 1)
+	if vpxImg == nil {
+		return nil, fmt.Errorf("vpx image allocation failed")
+	}
+	defer vpx.ImageFree(vpxImg)
+	vpxImg.Deref()
+
+	// Set Y, U, V planes (行ごとに stride を考慮してコピー)
+	cw := (e.width + 1) / 2
+	for plane, w := range [3]int{e.width, cw, cw} {
+		h := len(yuvData[plane]) / w
+		stride := int(vpxImg.Stride[plane])
+		dst := unsafe.Slice(vpxImg.Planes[plane], stride*h)
+		for row := 0; row < h; row++ {
+			copy(dst[row*stride:row*stride+w], yuvData[plane][row*w:(row+1)*w])
+		}
+	}
+	return e.encodeImage(vpxImg, yuvData[0], flags, start)
+}
+
+// encodeImage は入力済みの vpx 画像をエンコードします。srcY が nil なら SSIM を計算しません。
+func (e *Encoder) encodeImage(vpxImg *vpx.Image, srcY []byte, flags vpx.EncFrameFlags, start time.Time) ([]Packet, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.forceKf.Swap(false) {
+		flags |= vpx.EflagForceKf
+	}
+
+	layer := 0
+	if e.temporal != nil {
+		var layerFlags vpx.EncFrameFlags
+		layer, layerFlags = e.temporal.next(flags&vpx.EflagForceKf != 0)
+		flags |= layerFlags
+		if res := vpxext.ControlInt(e.ctx, vpxext.Vp8eSetTemporalLayerID, layer); res != vpx.CodecOk {
+			return nil, newCodecError(e.ctx, "テンポラルレイヤー ID 設定", res)
+		}
+	} else if e.recovery != nil {
+		flags |= e.recovery.NextFlags()
+	}
+
+	// エンコード実行
+	pts := e.pts
+	e.pts++
+	deadline := uint64(time.Now().UnixNano() / 1000) // マイクロ秒
+	if res := vpx.CodecEncode(e.ctx, vpxImg, vpx.CodecPts(pts), 1, flags, uint(deadline)); res != vpx.CodecOk {
+		return nil, newCodecError(e.ctx, e.codec.String()+"エンコード", res)
+	}
+
+	fs := FrameStats{Frame: pts}
+	packets, err := e.collect(flags, layer, &fs)
+	if err != nil {
+		return nil, err
+	}
+	if len(packets) > 0 && e.ssim && srcY != nil {
+		fs.SSIM = e.previewSSIM(srcY)
+	}
+	fs.EncodeTime = time.Since(start)
+	e.stats.add(fs)
+
+	return packets, nil
+}
+
+// Flush drains the frames the encoder is still holding back because of
+// lookahead (g_lag_in_frames). Call it once after the last frame when writing
+// files; the encoder cannot be used for further frames afterwards.
+func (e *Encoder) Flush() ([]Packet, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var packets []Packet
+	for {
+		start := time.Now()
+		deadline := uint64(time.Now().UnixNano() / 1000) // マイクロ秒
+		if res := vpx.CodecEncode(e.ctx, nil, 0, 0, 0, uint(deadline)); res != vpx.CodecOk {
+			return packets, newCodecError(e.ctx, e.codec.String()+"フラッシュ", res)
+		}
+		fs := FrameStats{Frame: e.pts}
+		pkts, err := e.collect(0, 0, &fs)
+		if err != nil {
+			return packets, err
+		}
+		if len(pkts) == 0 {
+			// 最後まで出力されなかったフレームも間引かれたものとして数える
+			if n := e.pts - e.shown; n > 0 && e.config.Pass != 1 {
+				e.stats.add(FrameStats{Frame: e.pts, Dropped: int(n)})
+				e.shown = e.pts
+			}
+			return packets, nil
+		}
+		fs.EncodeTime = time.Since(start)
+		e.stats.add(fs)
+		packets = append(packets, pkts...)
+	}
+}
+
+// collect はエンコード結果のパケットを取り出し、fs にサイズや PSNR を記録します。
+func (e *Encoder) collect(flags vpx.EncFrameFlags, layer int, fs *FrameStats) ([]Packet, error) {
+	var packets []Packet
+	var iter vpx.CodecIter = nil
+	for {
+		pkt := vpx.CodecGetCxData(e.ctx, &iter)
+		if pkt == nil {
+			break
+		}
+		pkt.Deref() // 必須
+		if pkt.Kind == vpx.CodecStatsPkt {
+			e.passStats = append(e.passStats, vpxext.TwoPassStats(pkt)...)
+		}
+		if pkt.Kind == vpx.CodecPsnrPkt {
+			psnr := vpxext.PSNR(pkt)
+			fs.PSNR, fs.PSNRY, fs.PSNRU, fs.PSNRV = psnr.PSNR[0], psnr.PSNR[1], psnr.PSNR[2], psnr.PSNR[3]
+		}
+		if pkt.Kind == vpx.CodecCxFramePkt {
+			frame := vpxext.Frame(pkt)
+			p := Packet{
+				Data:      frame.Data,
+				PTS:       frame.PTS,
+				Duration:  frame.Duration,
+				Keyframe:  frame.Flags&vpx.FrameIsKey != 0,
+				Droppable: frame.Flags&vpx.FrameIsDroppable != 0,
+				Flags:     flags,
+			}
+			fs.Size += len(p.Data)
+			fs.Keyframe = fs.Keyframe || p.Keyframe
+			if frame.Flags&vpx.FrameIsInvisible == 0 {
+				// 先読み中は出力が遅れるだけなので、表示フレームの PTS が飛んだときだけ
+				// その間の入力をレート制御が間引いたとみなす
+				fs.Dropped += int(max(p.PTS-e.shown, 0))
+				e.shown = p.PTS + 1
+			}
+			if e.svc {
+				layers, err := e.splitLayers(p)
+				if err != nil {
+					return nil, err
+				}
+				packets = append(packets, layers...)
+				continue
+			}
+			if e.temporal != nil {
+				e.temporal.annotate(&p, layer)
+			} else if e.recovery != nil {
+				e.recovery.OnPacket(p)
+			}
+			packets = append(packets, p)
+		}
+	}
+
+	if len(packets) > 0 {
+		if q, res := vpxext.ControlGetInt(e.ctx, vpxext.Vp8eGetLastQuantizer64); res == vpx.CodecOk {
+			fs.Quantizer = q
+		}
+	}
+	return packets, nil
+}
+
+// previewSSIM compares the source luma with the encoder's reconstruction of the
+// frame just encoded. It returns 0 when no preview frame is available.
+func (e *Encoder) previewSSIM(srcY []byte) float64 {
+	preview := vpx.CodecGetPreviewFrame(e.ctx)
+	if preview == nil {
+		return 0
+	}
+	preview.Deref()
+	w, h := min(int(preview.DW), e.width), min(int(preview.DH), e.height)
+	stride := int(preview.Stride[vpx.PlaneY])
+	recon := unsafe.Slice(preview.Planes[vpx.PlaneY], stride*h)
+	return ssimPlane(srcY, e.width, recon, stride, w, h)
+}
+
+// splitLayers splits a VP9 SVC superframe packet into one packet per spatial
+// layer, tagged with the spatial and temporal layer indices.
+func (e *Encoder) splitLayers(p Packet) ([]Packet, error) {
+	spatial, temporal, res := vpxext.SVCLayerID(e.ctx)
+	if res != vpx.CodecOk {
+		return nil, newCodecError(e.ctx, "SVC レイヤー ID 取得", res)
+	}
+
+	frames := splitVP9Superframe(p.Data)
+	layers := make([]Packet, len(frames))
+	for i, f := range frames {
+		lp := p
+		lp.Data = f.data
+		// スキップされたレイヤーがあっても番号がずれないよう、インデックス上の位置を使う。
+		// インデックスがなければ 1 レイヤーだけなので、最後にエンコードしたレイヤーの番号になる。
+		lp.SpatialLayerID = f.index
+		if f.index < 0 {
+			lp.SpatialLayerID = spatial
+		}
+		lp.TemporalLayerID = temporal
+		// 上位空間レイヤーはキーフレームでもベースレイヤーを参照する
+		lp.Keyframe = p.Keyframe && i == 0
+		lp.EndOfSuperframe = i == len(frames)-1
+		layers[i] = lp
+	}
+	return layers, nil
+}
+
+// FirstPassStats returns the statistics gathered by a first-pass encoder
+// (Pass 1). Call it after Flush and pass the result as TwoPassStats.
+func (e *Encoder) FirstPassStats() []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.passStats
+}
+
+// Close releases the encoder. It must not be used afterwards.
+func (e *Encoder) Close() {
+	if e.ctx != nil {
+		vpx.CodecDestroy(e.ctx)
+	}
+	if e.statsIn.Buf != nil {
+		vpxext.FreeFixedBuf(e.statsIn)
+		e.statsIn = vpx.FixedBuf{}
+	}
+}
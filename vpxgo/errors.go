@@ -0,0 +1,48 @@
+package vpxgo
+
+import (
+	"fmt"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// CodecError reports a failed libvpx call. It unwraps to the matching
+// vpx.ErrCodec* sentinel, so callers can use
+//
+//	errors.Is(err, vpx.ErrCodecInvalidParam)
+//
+// or errors.As with *CodecError to read the code and the codec's detail.
+type CodecError struct {
+	Op     string       // 失敗した処理 (例: "VP8エンコード")
+	Code   vpx.CodecErr // libvpx のエラーコード
+	Detail string       // vpx_codec_error_detail の内容 (無ければ空)
+}
+
+// newCodecError は ctx の詳細メッセージを添えた CodecError を返します。
+// ctx が nil (初期化前など) の場合は詳細を取得しません。
+func newCodecError(ctx *vpx.CodecCtx, op string, res vpx.CodecErr) error {
+	e := &CodecError{Op: op, Code: res}
+	if ctx != nil {
+		e.Detail = vpxext.ErrorDetail(ctx)
+	}
+	return e
+}
+
+func (e *CodecError) Error() string {
+	msg := fmt.Sprintf("%sエラー: %s", e.Op, e.Message())
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
+
+// Message returns libvpx's description of the error code.
+func (e *CodecError) Message() string {
+	return vpx.CodecErrToString(e.Code)
+}
+
+func (e *CodecError) Unwrap() error {
+	return vpx.Error(e.Code)
+}
@@ -0,0 +1,105 @@
+package vpxgo
+
+import (
+	"fmt"
+	"image"
+	"unsafe"
+
+	"github.com/xlab/libvpx-go/vpx"
+	"gocv.io/x/gocv"
+)
+
+// I420Frame is an 8-bit 4:2:0 picture with tightly packed planes.
+type I420Frame struct {
+	Width  int
+	Height int
+	Y      []byte
+	U      []byte
+	V      []byte
+}
+
+// NewI420Frame allocates a frame of the given size.
+func NewI420Frame(width, height int) *I420Frame {
+	cw, ch := (width+1)/2, (height+1)/2
+	return &I420Frame{
+		Width:  width,
+		Height: height,
+		Y:      make([]byte, width*height),
+		U:      make([]byte, cw*ch),
+		V:      make([]byte, cw*ch),
+	}
+}
+
+// ChromaWidth returns the width of the U and V planes.
+func (f *I420Frame) ChromaWidth() int { return (f.Width + 1) / 2 }
+
+// ChromaHeight returns the height of the U and V planes.
+func (f *I420Frame) ChromaHeight() int { return (f.Height + 1) / 2 }
+
+// MatToI420 converts a BGR Mat from gocv into an I420 frame.
+func MatToI420(mat gocv.Mat) (*I420Frame, error) {
+	img, err := mat.ToImage()
+	if err != nil {
+		return nil, fmt.Errorf("Mat変換エラー: %v", err)
+	}
+	w, h := mat.Cols(), mat.Rows()
+	yuv := RGBToYUV420(img, w, h)
+	return &I420Frame{Width: w, Height: h, Y: yuv[0], U: yuv[1], V: yuv[2]}, nil
+}
+
+// i420FromImage copies an 8-bit I420 vpx image (e.g. decoder output) into Go
+// memory, dropping the row padding.
+func i420FromImage(img *vpx.Image) (*I420Frame, error) {
+	if img.Fmt != vpx.ImageFormatI420 {
+		return nil, fmt.Errorf("未対応の画像フォーマット: %v", img.Fmt)
+	}
+	f := NewI420Frame(int(img.DW), int(img.DH))
+	planes := [3][]byte{f.Y, f.U, f.V}
+	for i, dst := range planes {
+		w, h := f.Width, f.Height
+		if i > 0 {
+			w, h = f.ChromaWidth(), f.ChromaHeight()
+		}
+		stride := int(img.Stride[i])
+		src := unsafe.Slice(img.Planes[i], stride*h)
+		for row := 0; row < h; row++ {
+			copy(dst[row*w:(row+1)*w], src[row*stride:row*stride+w])
+		}
+	}
+	return f, nil
+}
+
+// ScaleI420 resizes each plane of f with gocv. Area interpolation is used for
+// downscaling and bilinear for upscaling.
+func ScaleI420(f *I420Frame, width, height int) (*I420Frame, error) {
+	if f.Width == width && f.Height == height {
+		return f, nil
+	}
+	interp := gocv.InterpolationArea
+	if width > f.Width {
+		interp = gocv.InterpolationLinear
+	}
+	out := NewI420Frame(width, height)
+	src := [3][]byte{f.Y, f.U, f.V}
+	dst := [3][]byte{out.Y, out.U, out.V}
+	for i := range src {
+		sw, sh, dw, dh := f.Width, f.Height, width, height
+		if i > 0 {
+			sw, sh, dw, dh = f.ChromaWidth(), f.ChromaHeight(), out.ChromaWidth(), out.ChromaHeight()
+		}
+		in, err := gocv.NewMatFromBytes(sh, sw, gocv.MatTypeCV8U, src[i])
+		if err != nil {
+			return nil, fmt.Errorf("スケーリング用 Mat 作成エラー: %v", err)
+		}
+		scaled := gocv.NewMat()
+		err = gocv.Resize(in, &scaled, image.Pt(dw, dh), 0, 0, interp)
+		in.Close()
+		if err != nil {
+			scaled.Close()
+			return nil, fmt.Errorf("スケーリングエラー: %v", err)
+		}
+		copy(dst[i], scaled.ToBytes())
+		scaled.Close()
+	}
+	return out, nil
+}
@@ -0,0 +1,214 @@
+package vpxgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"github.com/xlab/libvpx-go/vpx"
+	"gocv.io/x/gocv"
+)
+
+// Frame16 is a picture with 16-bit samples, used for VP9 profiles 1-3
+// (4:4:4 and/or 10/12-bit). Samples use the low BitDepth bits.
+type Frame16 struct {
+	Width     int
+	Height    int
+	BitDepth  int  // 8, 10 または 12
+	Chroma444 bool // false なら 4:2:0
+	Y         []uint16
+	U         []uint16
+	V         []uint16
+}
+
+// NewFrame16 allocates a frame of the given size and format.
+func NewFrame16(width, height, bitDepth int, chroma444 bool) *Frame16 {
+	f := &Frame16{Width: width, Height: height, BitDepth: bitDepth, Chroma444: chroma444}
+	c := f.ChromaWidth() * f.ChromaHeight()
+	f.Y = make([]uint16, width*height)
+	f.U = make([]uint16, c)
+	f.V = make([]uint16, c)
+	return f
+}
+
+// ChromaWidth returns the width of the U and V planes.
+func (f *Frame16) ChromaWidth() int {
+	if f.Chroma444 {
+		return f.Width
+	}
+	return (f.Width + 1) / 2
+}
+
+// ChromaHeight returns the height of the U and V planes.
+func (f *Frame16) ChromaHeight() int {
+	if f.Chroma444 {
+		return f.Height
+	}
+	return (f.Height + 1) / 2
+}
+
+// Frame16FromI420 widens an 8-bit I420 frame to bitDepth bits.
+func Frame16FromI420(src *I420Frame, bitDepth int) *Frame16 {
+	f := NewFrame16(src.Width, src.Height, bitDepth, false)
+	shift := uint(bitDepth - 8)
+	for i, p := range [3][]byte{src.Y, src.U, src.V} {
+		dst := [3][]uint16{f.Y, f.U, f.V}[i]
+		for j, v := range p {
+			dst[j] = uint16(v) << shift
+		}
+	}
+	return f
+}
+
+// Equal reports whether f and o have the same format and identical samples.
+func (f *Frame16) Equal(o *Frame16) bool {
+	if f.Width != o.Width || f.Height != o.Height || f.BitDepth != o.BitDepth || f.Chroma444 != o.Chroma444 {
+		return false
+	}
+	for i, p := range [3][]uint16{f.Y, f.U, f.V} {
+		q := [3][]uint16{o.Y, o.U, o.V}[i]
+		for j := range p {
+			if p[j] != q[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MatToFrame16 converts a BGR Mat with 8-bit (CV_8UC3) or 16-bit (CV_16UC3)
+// channels into a bitDepth-bit YUV frame (BT.601, limited range), keeping the
+// source precision. 4:2:0 chroma is the average of each 2x2 block.
+func MatToFrame16(mat gocv.Mat, bitDepth int, chroma444 bool) (*Frame16, error) {
+	w, h := mat.Cols(), mat.Rows()
+	var maxIn float64
+	var sample func(buf []byte, i int) float64
+	switch mat.Type() {
+	case gocv.MatTypeCV8UC3:
+		maxIn = 255
+		sample = func(buf []byte, i int) float64 { return float64(buf[i]) }
+	case gocv.MatTypeCV16UC3:
+		maxIn = 65535
+		sample = func(buf []byte, i int) float64 { return float64(binary.LittleEndian.Uint16(buf[2*i:])) }
+	default:
+		return nil, fmt.Errorf("未対応の Mat 形式です (CV_8UC3 または CV_16UC3): %v", mat.Type())
+	}
+	buf := mat.ToBytes()
+
+	f := NewFrame16(w, h, bitDepth, chroma444)
+	scale := float64(int(1) << uint(bitDepth-8))
+	cw := f.ChromaWidth()
+	var uAcc, vAcc []float64
+	if !chroma444 {
+		uAcc = make([]float64, len(f.U))
+		vAcc = make([]float64, len(f.V))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := (y*w + x) * 3
+			b, g, r := sample(buf, i)/maxIn, sample(buf, i+1)/maxIn, sample(buf, i+2)/maxIn
+			yy := 16 + 65.481*r + 128.553*g + 24.966*b
+			u := 128 - 37.797*r - 74.203*g + 112*b
+			v := 128 + 112*r - 93.786*g - 18.214*b
+			f.Y[y*w+x] = uint16(yy*scale + 0.5)
+			if chroma444 {
+				f.U[y*w+x] = uint16(u*scale + 0.5)
+				f.V[y*w+x] = uint16(v*scale + 0.5)
+			} else {
+				c := (y/2)*cw + x/2
+				uAcc[c] += u
+				vAcc[c] += v
+			}
+		}
+	}
+	if !chroma444 {
+		for cy := 0; cy < f.ChromaHeight(); cy++ {
+			for cx := 0; cx < cw; cx++ {
+				// 右端・下端の奇数サイズでは平均する画素数が減る
+				n := float64(min(2, w-2*cx) * min(2, h-2*cy))
+				c := cy*cw + cx
+				f.U[c] = uint16(uAcc[c]/n*scale + 0.5)
+				f.V[c] = uint16(vAcc[c]/n*scale + 0.5)
+			}
+		}
+	}
+	return f, nil
+}
+
+// imageFormat16 は Frame16 の形式に対応する vpx の画像形式を返します。
+func imageFormat16(bitDepth int, chroma444 bool) vpx.ImageFormat {
+	switch {
+	case bitDepth > 8 && chroma444:
+		return vpx.ImageFormatI44416
+	case bitDepth > 8:
+		return vpx.ImageFormatI42016
+	case chroma444:
+		return vpx.ImageFormatI444
+	}
+	return vpx.ImageFormatI420
+}
+
+// copyToImage は f の各プレーンを vpx 画像へ stride を考慮してコピーします。
+func (f *Frame16) copyToImage(img *vpx.Image) {
+	high := img.Fmt&vpx.ImageFormatHighbitdepth != 0
+	for i, src := range [3][]uint16{f.Y, f.U, f.V} {
+		w, h := f.Width, f.Height
+		if i > 0 {
+			w, h = f.ChromaWidth(), f.ChromaHeight()
+		}
+		stride := int(img.Stride[i])
+		if high {
+			dst := unsafe.Slice((*uint16)(unsafe.Pointer(img.Planes[i])), stride/2*h)
+			for row := 0; row < h; row++ {
+				copy(dst[row*stride/2:row*stride/2+w], src[row*w:(row+1)*w])
+			}
+			continue
+		}
+		dst := unsafe.Slice(img.Planes[i], stride*h)
+		for row := 0; row < h; row++ {
+			for x := 0; x < w; x++ {
+				dst[row*stride+x] = byte(src[row*w+x])
+			}
+		}
+	}
+}
+
+// frame16FromImage は 8/16 ビットの I420/I444 画像 (デコーダー出力など) を Frame16 にコピーします。
+func frame16FromImage(img *vpx.Image) (*Frame16, error) {
+	high := img.Fmt&vpx.ImageFormatHighbitdepth != 0
+	var chroma444 bool
+	switch img.Fmt &^ vpx.ImageFormatHighbitdepth {
+	case vpx.ImageFormatI420:
+	case vpx.ImageFormatI444:
+		chroma444 = true
+	default:
+		return nil, fmt.Errorf("未対応の画像フォーマット: %v", img.Fmt)
+	}
+	depth := int(img.BitDepth)
+	if !high || depth == 0 {
+		depth = 8
+	}
+
+	f := NewFrame16(int(img.DW), int(img.DH), depth, chroma444)
+	for i, dst := range [3][]uint16{f.Y, f.U, f.V} {
+		w, h := f.Width, f.Height
+		if i > 0 {
+			w, h = f.ChromaWidth(), f.ChromaHeight()
+		}
+		stride := int(img.Stride[i])
+		if high {
+			src := unsafe.Slice((*uint16)(unsafe.Pointer(img.Planes[i])), stride/2*h)
+			for row := 0; row < h; row++ {
+				copy(dst[row*w:(row+1)*w], src[row*stride/2:row*stride/2+w])
+			}
+			continue
+		}
+		src := unsafe.Slice(img.Planes[i], stride*h)
+		for row := 0; row < h; row++ {
+			for x := 0; x < w; x++ {
+				dst[row*w+x] = uint16(src[row*stride+x])
+			}
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,170 @@
+package vpxgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// IVF は libvpx のサンプルツールが使う単純なコンテナです。
+// 32 バイトのファイルヘッダの後に、12 バイトのフレームヘッダ (サイズ, pts) とデータが続きます。
+const (
+	ivfFileHeaderSize  = 32
+	ivfFrameHeaderSize = 12
+)
+
+// IVFReader reads frames from an IVF file.
+type IVFReader struct {
+	r        io.Reader
+	info     StreamInfo
+	timebase [2]uint32 // 秒 = pts * timebase[0] / timebase[1]
+}
+
+// NewIVFReader reads the IVF file header from r.
+func NewIVFReader(r io.Reader) (*IVFReader, error) {
+	var h [ivfFileHeaderSize]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, fmt.Errorf("IVF ヘッダ読み込みエラー: %v", err)
+	}
+	if string(h[0:4]) != "DKIF" {
+		return nil, fmt.Errorf("IVF ファイルではありません")
+	}
+	hdrSize := int(binary.LittleEndian.Uint16(h[6:8]))
+	if hdrSize > ivfFileHeaderSize {
+		if _, err := io.CopyN(io.Discard, r, int64(hdrSize-ivfFileHeaderSize)); err != nil {
+			return nil, fmt.Errorf("IVF ヘッダ読み込みエラー: %v", err)
+		}
+	}
+
+	ivf := &IVFReader{r: r}
+	switch string(h[8:12]) {
+	case "VP80":
+		ivf.info.Codec = CodecVP8
+	case "VP90":
+		ivf.info.Codec = CodecVP9
+	default:
+		return nil, fmt.Errorf("未対応の IVF コーデック: %q", h[8:12])
+	}
+	ivf.info.Width = int(binary.LittleEndian.Uint16(h[12:14]))
+	ivf.info.Height = int(binary.LittleEndian.Uint16(h[14:16]))
+	// ヘッダはフレームレート (rate/scale) を持ち、pts の単位はその逆数
+	rate := binary.LittleEndian.Uint32(h[16:20])
+	scale := binary.LittleEndian.Uint32(h[20:24])
+	if rate == 0 || scale == 0 {
+		rate, scale = 30, 1
+	}
+	ivf.timebase = [2]uint32{scale, rate}
+	return ivf, nil
+}
+
+func (r *IVFReader) Info() StreamInfo { return r.info }
+
+func (r *IVFReader) ReadPacket() (ContainerPacket, error) {
+	var h [ivfFrameHeaderSize]byte
+	if _, err := io.ReadFull(r.r, h[:]); err != nil {
+		if err == io.EOF {
+			return ContainerPacket{}, io.EOF
+		}
+		return ContainerPacket{}, fmt.Errorf("IVF フレームヘッダ読み込みエラー: %v", err)
+	}
+	size := binary.LittleEndian.Uint32(h[0:4])
+	pts := int64(binary.LittleEndian.Uint64(h[4:12]))
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return ContainerPacket{}, fmt.Errorf("IVF フレーム読み込みエラー: %v", err)
+	}
+
+	ts := time.Duration(pts) * time.Second * time.Duration(r.timebase[0]) / time.Duration(r.timebase[1])
+	return ContainerPacket{Data: data, Timestamp: ts, Keyframe: isKeyframe(r.info.Codec, data)}, nil
+}
+
+// isKeyframe inspects the uncompressed header of a VP8/VP9 frame.
+func isKeyframe(codec Codec, data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	if codec == CodecVP8 {
+		// frame tag の最下位ビットが 0 ならキーフレーム
+		return data[0]&0x01 == 0
+	}
+	// VP9: frame_marker(2) profile(2〜3) show_existing_frame(1) frame_type(1)
+	b := data[0]
+	profile := int(b>>5&1) | int(b>>4&1)<<1
+	shift := 3
+	if profile == 3 {
+		shift = 2
+	}
+	if b>>shift&1 == 1 { // show_existing_frame
+		return false
+	}
+	return b>>(shift-1)&1 == 0
+}
+
+// IVFWriter writes encoded packets to an IVF file.
+type IVFWriter struct {
+	w      io.Writer
+	frames uint32
+}
+
+// NewIVFWriter writes the IVF file header. The timebase is 1/fps, matching
+// the pts of packets produced by Encoder.
+func NewIVFWriter(w io.Writer, codec Codec, width, height, fps int) (*IVFWriter, error) {
+	return newIVFWriter(w, codec, width, height, 1, fps)
+}
+
+// newIVFWriter はタイムベース num/den 秒のヘッダを書きます。
+func newIVFWriter(w io.Writer, codec Codec, width, height, num, den int) (*IVFWriter, error) {
+	var h [ivfFileHeaderSize]byte
+	copy(h[0:4], "DKIF")
+	binary.LittleEndian.PutUint16(h[4:6], 0)
+	binary.LittleEndian.PutUint16(h[6:8], ivfFileHeaderSize)
+	fourcc := "VP80"
+	if codec == CodecVP9 {
+		fourcc = "VP90"
+	}
+	copy(h[8:12], fourcc)
+	binary.LittleEndian.PutUint16(h[12:14], uint16(width))
+	binary.LittleEndian.PutUint16(h[14:16], uint16(height))
+	binary.LittleEndian.PutUint32(h[16:20], uint32(den))
+	binary.LittleEndian.PutUint32(h[20:24], uint32(num))
+	if _, err := w.Write(h[:]); err != nil {
+		return nil, fmt.Errorf("IVF ヘッダ書き込みエラー: %v", err)
+	}
+	return &IVFWriter{w: w}, nil
+}
+
+// WritePacket appends one frame. SVC layer packets of the same superframe must
+// be joined (see SuperframeUpTo) before writing.
+func (iw *IVFWriter) WritePacket(p Packet) error {
+	var h [ivfFrameHeaderSize]byte
+	binary.LittleEndian.PutUint32(h[0:4], uint32(len(p.Data)))
+	binary.LittleEndian.PutUint64(h[4:12], uint64(p.PTS))
+	if _, err := iw.w.Write(h[:]); err != nil {
+		return fmt.Errorf("IVF 書き込みエラー: %v", err)
+	}
+	if _, err := iw.w.Write(p.Data); err != nil {
+		return fmt.Errorf("IVF 書き込みエラー: %v", err)
+	}
+	iw.frames++
+	return nil
+}
+
+// Close fills in the frame count of the header when the underlying writer
+// supports seeking. It does not close the writer.
+func (iw *IVFWriter) Close() error {
+	ws, ok := iw.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], iw.frames)
+	if _, err := ws.Seek(24, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := ws.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := ws.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,194 @@
+package vpxgo
+
+import (
+	"net/http"
+	"sync"
+)
+
+// liveMaxGOP は新しい視聴者のために保持する GOP の最大パケット数です。
+// これに達したら保持を破棄してキーフレームを要求します。
+const liveMaxGOP = 300
+
+// liveClientQueue は視聴者ごとの送信待ちパケット数の上限です。
+const liveClientQueue = 120
+
+// LiveStream fans the packets of one encoder out to any number of HTTP
+// viewers as a continuous WebM stream (chunked transfer), suitable for a
+// plain <video> element. Each viewer gets a fresh WebM header and starts at
+// the most recent keyframe; the packets since that keyframe are replayed
+// first. A viewer that cannot keep up skips ahead to the next keyframe,
+// which is requested right away, instead of slowing down the encoder.
+//
+// One packet per frame is expected, so VP9 SVC output is not supported.
+type LiveStream struct {
+	codec  Codec
+	width  int
+	height int
+	fps    int
+
+	// RequestKeyframe is called when a viewer joins late in a long GOP, when
+	// a viewer falls behind and waits for a keyframe, and when the cached GOP
+	// grows too long, typically Encoder.RequestKeyframe.
+	RequestKeyframe func()
+
+	mu      sync.Mutex
+	gop     []Packet // 最新のキーフレーム以降のパケット
+	clients map[*liveClient]struct{}
+	closed  bool
+}
+
+type liveClient struct {
+	ch      chan Packet
+	waitKey bool // 取りこぼし後、次のキーフレームまで送信しない
+}
+
+// NewLiveStream creates a stream for packets produced by an encoder with the
+// given codec, size and frame rate.
+func NewLiveStream(codec Codec, width, height, fps int) *LiveStream {
+	return &LiveStream{
+		codec:   codec,
+		width:   width,
+		height:  height,
+		fps:     fps,
+		clients: make(map[*liveClient]struct{}),
+	}
+}
+
+// WritePacket distributes one encoded packet to all viewers. It never
+// blocks on a slow viewer.
+func (s *LiveStream) WritePacket(p Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+
+	if p.Keyframe {
+		s.gop = s.gop[:0]
+	}
+	if len(s.gop) > 0 || p.Keyframe {
+		s.gop = append(s.gop, p)
+		if len(s.gop) >= liveMaxGOP {
+			// 保持をやめ、新しい視聴者には次のキーフレームを待たせる
+			s.gop = s.gop[:0]
+			if s.RequestKeyframe != nil {
+				s.RequestKeyframe()
+			}
+		}
+	}
+
+	behind := false
+	for c := range s.clients {
+		if c.waitKey && !p.Keyframe {
+			continue
+		}
+		select {
+		case c.ch <- p:
+			c.waitKey = false
+		default:
+			// 送信が追いつかない視聴者は次のキーフレームから再開する
+			c.waitKey = true
+			behind = true
+		}
+	}
+	if behind && s.RequestKeyframe != nil {
+		// 周期的なキーフレームまで待たせないよう、すぐにキーフレームを要求する
+		s.RequestKeyframe()
+	}
+	return nil
+}
+
+// Close disconnects all viewers. It implements PacketWriter.
+func (s *LiveStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		for c := range s.clients {
+			close(c.ch)
+		}
+		s.clients = nil
+	}
+	return nil
+}
+
+// Viewers returns the number of connected viewers.
+func (s *LiveStream) Viewers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// ServeHTTP streams WebM to the client until it disconnects or the stream is
+// closed.
+func (s *LiveStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.subscribe()
+	if !ok {
+		http.Error(w, "配信は終了しました", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "video/webm")
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	flusher, _ := w.(http.Flusher)
+
+	// シークできない Writer なのでサイズ未確定の WebM になる
+	ww, err := NewWebMWriter(w, s.codec, s.width, s.height, s.fps)
+	if err != nil {
+		return
+	}
+	base := int64(-1)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case p, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			// 視聴者ごとにタイムスタンプを 0 から始める
+			if base < 0 {
+				base = p.PTS
+			}
+			p.PTS -= base
+			if err := ww.WritePacket(p); err != nil {
+				return
+			}
+			if flusher != nil && len(c.ch) == 0 {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// subscribe は視聴者を登録し、最新の GOP を送信キューに積みます。
+func (s *LiveStream) subscribe() (*liveClient, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, false
+	}
+	c := &liveClient{ch: make(chan Packet, liveMaxGOP+liveClientQueue), waitKey: true}
+	if len(s.gop) > 0 {
+		for _, p := range s.gop {
+			c.ch <- p
+		}
+		c.waitKey = false
+	}
+	s.clients[c] = struct{}{}
+	if s.RequestKeyframe != nil && len(s.gop) > s.fps {
+		// 再生開始までの遅延を縮めるため、長い GOP の途中ならキーフレームを要求する
+		s.RequestKeyframe()
+	}
+	return c, true
+}
+
+func (s *LiveStream) unsubscribe(c *liveClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.ch)
+	}
+}
@@ -0,0 +1,46 @@
+package vpxgo
+
+import "testing"
+
+func TestLiveStreamSlowViewerRequestsKeyframe(t *testing.T) {
+	s := NewLiveStream(CodecVP8, 64, 48, 30)
+	var requests int
+	s.RequestKeyframe = func() { requests++ }
+	c, ok := s.subscribe()
+	if !ok {
+		t.Fatal("subscribe に失敗しました")
+	}
+
+	pkt := func(pts int64, key bool) Packet { return Packet{Data: []byte{0}, PTS: pts, Keyframe: key} }
+	// 視聴者がまったく読まないので、キューがあふれた時点で取りこぼす。
+	// GOP の保持上限には達しないようにキーフレームを挟む
+	for i := 0; i < cap(c.ch); i++ {
+		s.WritePacket(pkt(int64(i), i%100 == 0))
+	}
+	if requests != 0 {
+		t.Fatalf("キューがあふれる前にキーフレームを要求しました: %d", requests)
+	}
+	n := int64(cap(c.ch))
+	s.WritePacket(pkt(n, false))
+	if !c.waitKey || requests != 1 {
+		t.Fatalf("取りこぼし後: waitKey %v, 要求 %d, want true 1", c.waitKey, requests)
+	}
+	// キーフレーム待ちの間は要求を繰り返さない
+	s.WritePacket(pkt(n+1, false))
+	if requests != 1 {
+		t.Fatalf("要求 %d, want 1", requests)
+	}
+
+	// 視聴者が追いつけば、次のキーフレームから再開する
+	for len(c.ch) > 0 {
+		<-c.ch
+	}
+	s.WritePacket(pkt(n+2, false))
+	s.WritePacket(pkt(n+3, true))
+	if len(c.ch) != 1 || c.waitKey {
+		t.Fatalf("キュー %d, waitKey %v, want 1 false", len(c.ch), c.waitKey)
+	}
+	if p := <-c.ch; p.PTS != n+3 {
+		t.Errorf("再開したパケットの PTS %d, want %d", p.PTS, n+3)
+	}
+}
@@ -0,0 +1,181 @@
+package vpxgo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics exports capture/encode telemetry in the Prometheus format.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	framesIn     prometheus.Counter
+	framesOut    prometheus.Counter
+	dropped      prometheus.Counter
+	keyframes    prometheus.Counter
+	bytes        prometheus.Counter
+	encodeTime   prometheus.Histogram
+	fractionLost prometheus.Gauge
+	packetsLost  prometheus.Gauge
+
+	inRate, outRate, bitRate windowRate
+}
+
+// NewMetrics creates the encoder metrics on their own Prometheus registry.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		framesIn: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vpx_frames_captured_total", Help: "Frames read from the capture source.",
+		}),
+		framesOut: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vpx_frames_encoded_total", Help: "Frames that produced compressed output.",
+		}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vpx_frames_dropped_total", Help: "Frames dropped by capture errors or rate control.",
+		}),
+		keyframes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vpx_keyframes_total", Help: "Keyframes produced by the encoder.",
+		}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "vpx_encoded_bytes_total", Help: "Compressed bytes produced by the encoder.",
+		}),
+		encodeTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "vpx_encode_duration_seconds",
+			Help:    "Time spent converting and encoding one frame.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 10), // 1ms .. 512ms
+		}),
+		fractionLost: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vpx_rtcp_fraction_lost", Help: "Fraction of RTP packets lost, from the last RTCP receiver report.",
+		}),
+		packetsLost: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "vpx_rtcp_packets_lost", Help: "Cumulative RTP packets lost, from the last RTCP receiver report.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.framesIn, m.framesOut, m.dropped, m.keyframes, m.bytes, m.encodeTime,
+		m.fractionLost, m.packetsLost,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "vpx_input_fps", Help: "Captured frames per second over the last few seconds.",
+		}, m.inRate.rate),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "vpx_output_fps", Help: "Encoded frames per second over the last few seconds.",
+		}, m.outRate.rate),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "vpx_bitrate_kbps", Help: "Encoder output bitrate over the last few seconds.",
+		}, func() float64 { return m.bitRate.rate() * 8 / 1000 }),
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics for scraping.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// FrameCaptured counts a frame read from the capture source.
+func (m *Metrics) FrameCaptured() {
+	m.framesIn.Inc()
+	m.inRate.add(1)
+}
+
+// FrameDropped counts a frame lost before reaching the encoder.
+func (m *Metrics) FrameDropped() {
+	m.dropped.Inc()
+}
+
+// FrameEncoded records the statistics of one encode call. Frames that rate
+// control skipped are counted as dropped; a call without output while the
+// encoder is still looking ahead is not.
+func (m *Metrics) FrameEncoded(f FrameStats) {
+	m.encodeTime.Observe(f.EncodeTime.Seconds())
+	m.dropped.Add(float64(f.Dropped))
+	if f.Size == 0 {
+		return
+	}
+	m.framesOut.Inc()
+	m.outRate.add(1)
+	if f.Keyframe {
+		m.keyframes.Inc()
+	}
+	m.bytes.Add(float64(f.Size))
+	m.bitRate.add(float64(f.Size))
+}
+
+// ObserveRTCP updates the packet loss gauges from a (compound) RTCP packet
+// containing sender or receiver reports.
+func (m *Metrics) ObserveRTCP(b []byte) error {
+	for len(b) >= 4 {
+		if b[0]>>6 != 2 {
+			return fmt.Errorf("RTCP バージョンが不正です: %d", b[0]>>6)
+		}
+		count := int(b[0] & 0x1f)
+		pt := b[1]
+		length := (int(binary.BigEndian.Uint16(b[2:4])) + 1) * 4
+		if length > len(b) {
+			return fmt.Errorf("RTCP パケットが途中で切れています")
+		}
+		pkt := b[:length]
+		b = b[length:]
+
+		var blocks []byte
+		switch pt {
+		case 200: // SR: ヘッダ 8 バイト + 送信者情報 20 バイト
+			if len(pkt) >= 28 {
+				blocks = pkt[28:]
+			}
+		case 201: // RR: ヘッダ 8 バイト
+			if len(pkt) >= 8 {
+				blocks = pkt[8:]
+			}
+		default:
+			continue
+		}
+		// 複数のレポートブロックがある場合は最後のものを採用する
+		for i := 0; i < count && len(blocks) >= 24; i++ {
+			m.fractionLost.Set(float64(blocks[4]) / 256)
+			lost := int32(uint32(blocks[5])<<16|uint32(blocks[6])<<8|uint32(blocks[7])) << 8 >> 8
+			m.packetsLost.Set(float64(lost))
+			blocks = blocks[24:]
+		}
+	}
+	return nil
+}
+
+// windowRate measures events per second over the last complete seconds.
+type windowRate struct {
+	mu      sync.Mutex
+	buckets [5]float64
+	stamps  [5]int64
+}
+
+func (w *windowRate) add(v float64) {
+	sec := time.Now().Unix()
+	i := sec % int64(len(w.buckets))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stamps[i] != sec {
+		w.stamps[i], w.buckets[i] = sec, 0
+	}
+	w.buckets[i] += v
+}
+
+func (w *windowRate) rate() float64 {
+	now := time.Now().Unix()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var sum float64
+	for i, s := range w.stamps {
+		// 集計中の現在の秒は除く
+		if s < now && s >= now-int64(len(w.buckets)-1) {
+			sum += w.buckets[i]
+		}
+	}
+	return sum / float64(len(w.buckets)-1)
+}
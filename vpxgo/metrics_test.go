@@ -0,0 +1,76 @@
+package vpxgo
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// rtcpReport は blocks 個のレポートブロックを持つ RR (pt=201) または SR (pt=200) を作ります。
+func rtcpReport(pt byte, fractionLost byte, lost int32, blocks int) []byte {
+	hdr := 8
+	if pt == 200 {
+		hdr = 28
+	}
+	b := make([]byte, hdr+24*blocks)
+	b[0] = 0x80 | byte(blocks)
+	b[1] = pt
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)/4-1))
+	for i := 0; i < blocks; i++ {
+		blk := b[hdr+24*i:]
+		blk[4] = fractionLost
+		blk[5], blk[6], blk[7] = byte(lost>>16), byte(lost>>8), byte(lost)
+	}
+	return b
+}
+
+func TestMetricsObserveRTCP(t *testing.T) {
+	m := NewMetrics()
+	// SR に続けて RR を送る複合パケット。最後のレポートブロックの値が残る
+	b := append(rtcpReport(200, 0, 1, 1), rtcpReport(201, 64, 5, 2)...)
+	if err := m.ObserveRTCP(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(m.fractionLost); got != 0.25 {
+		t.Errorf("fraction lost %v, want 0.25", got)
+	}
+	if got := testutil.ToFloat64(m.packetsLost); got != 5 {
+		t.Errorf("packets lost %v, want 5", got)
+	}
+
+	// 累積損失数は 24 ビットの符号付き整数 (重複受信で負になりうる)
+	if err := m.ObserveRTCP(rtcpReport(201, 0, -1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(m.packetsLost); got != -1 {
+		t.Errorf("packets lost %v, want -1", got)
+	}
+
+	if err := m.ObserveRTCP(rtcpReport(201, 0, 0, 1)[:20]); err == nil {
+		t.Error("途中で切れたパケットでエラーになりません")
+	}
+	bad := rtcpReport(201, 0, 0, 1)
+	bad[0] = 0x40 | 1
+	if err := m.ObserveRTCP(bad); err == nil {
+		t.Error("バージョン 1 のパケットでエラーになりません")
+	}
+}
+
+func TestMetricsFrameEncodedDropped(t *testing.T) {
+	m := NewMetrics()
+	// 先読み中で出力のない呼び出しは間引きではない
+	m.FrameEncoded(FrameStats{Frame: 0})
+	m.FrameEncoded(FrameStats{Frame: 1})
+	m.FrameEncoded(FrameStats{Frame: 2, Size: 100, Keyframe: true})
+	m.FrameEncoded(FrameStats{Frame: 5, Size: 50, Dropped: 2})
+	if got := testutil.ToFloat64(m.dropped); got != 2 {
+		t.Errorf("dropped %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.framesOut); got != 2 {
+		t.Errorf("encoded %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.keyframes); got != 1 {
+		t.Errorf("keyframes %v, want 1", got)
+	}
+}
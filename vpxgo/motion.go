@@ -0,0 +1,207 @@
+package vpxgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// MotionDetectorConfig configures MotionDetector. Zero values select the
+// defaults noted on each field.
+type MotionDetectorConfig struct {
+	// Sensitivity は 0..1 で、大きいほど小さな変化も動きとみなす (既定 0.5)
+	Sensitivity float64
+	// MinArea は動きとみなす領域の最小面積 (元画像の面積に対する割合、既定 0.002)
+	MinArea float64
+	// Masks は検出から除外する領域 (元画像の座標、時計や木の揺れなど)
+	Masks []image.Rectangle
+	// ProcessWidth は検出用に縮小する幅 (既定 320)
+	ProcessWidth int
+	// History は背景モデルの学習に使うフレーム数 (既定 500)
+	History int
+	// Cooldown は動きが止まってから終了イベントを出すまでの時間 (既定 2 秒)
+	Cooldown time.Duration
+}
+
+// MotionEventType distinguishes the start and the end of a motion period.
+type MotionEventType string
+
+const (
+	MotionStart MotionEventType = "start"
+	MotionStop  MotionEventType = "stop"
+)
+
+// MotionEvent is emitted when motion starts or, after Cooldown without
+// motion, stops. Boxes are in source image coordinates.
+type MotionEvent struct {
+	Type  MotionEventType
+	Time  time.Time
+	Frame int64
+	Boxes []image.Rectangle
+}
+
+// MarshalJSON はボックスを {x, y, w, h} の配列で出力します。
+func (e MotionEvent) MarshalJSON() ([]byte, error) {
+	type box struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+		W int `json:"w"`
+		H int `json:"h"`
+	}
+	boxes := make([]box, len(e.Boxes))
+	for i, r := range e.Boxes {
+		boxes[i] = box{r.Min.X, r.Min.Y, r.Dx(), r.Dy()}
+	}
+	return json.Marshal(struct {
+		Type  MotionEventType `json:"type"`
+		Time  time.Time       `json:"time"`
+		Frame int64           `json:"frame"`
+		Boxes []box           `json:"boxes"`
+	}{e.Type, e.Time, e.Frame, boxes})
+}
+
+// MotionDetector finds moving regions with MOG2 background subtraction on
+// the same BGR Mats that are fed to the encoder. Detect returns the bounding
+// boxes of every frame; OnEvent is called at the start and end of motion.
+type MotionDetector struct {
+	cfg MotionDetectorConfig
+
+	// OnEvent is called synchronously from Detect.
+	OnEvent func(MotionEvent)
+
+	mog2   gocv.BackgroundSubtractorMOG2
+	kernel gocv.Mat
+	small  gocv.Mat
+	fg     gocv.Mat
+
+	frame      int64
+	active     bool
+	lastMotion time.Time
+	lastBoxes  []image.Rectangle
+}
+
+// NewMotionDetector prepares the background model.
+func NewMotionDetector(cfg MotionDetectorConfig) (*MotionDetector, error) {
+	if cfg.Sensitivity == 0 {
+		cfg.Sensitivity = 0.5
+	}
+	if cfg.Sensitivity < 0 || cfg.Sensitivity > 1 {
+		return nil, fmt.Errorf("Sensitivity は 0 から 1 の範囲で指定してください: %v", cfg.Sensitivity)
+	}
+	if cfg.MinArea == 0 {
+		cfg.MinArea = 0.002
+	}
+	if cfg.ProcessWidth == 0 {
+		cfg.ProcessWidth = 320
+	}
+	if cfg.History == 0 {
+		cfg.History = 500
+	}
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = 2 * time.Second
+	}
+
+	// 感度 1 で varThreshold 8、感度 0 で 64 (OpenCV の既定は 16)
+	varThreshold := 8 + (1-cfg.Sensitivity)*56
+	return &MotionDetector{
+		cfg:    cfg,
+		mog2:   gocv.NewBackgroundSubtractorMOG2WithParams(cfg.History, varThreshold, false),
+		kernel: gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(3, 3)),
+		small:  gocv.NewMat(),
+		fg:     gocv.NewMat(),
+	}, nil
+}
+
+// Detect updates the background model with mat and returns the bounding
+// boxes of the moving regions outside the masks.
+func (d *MotionDetector) Detect(mat gocv.Mat) ([]image.Rectangle, error) {
+	d.frame++
+	w, h := mat.Cols(), mat.Rows()
+	scale := 1.0
+	if w > d.cfg.ProcessWidth {
+		scale = float64(d.cfg.ProcessWidth) / float64(w)
+	}
+	sw, sh := int(float64(w)*scale), int(float64(h)*scale)
+	if err := gocv.Resize(mat, &d.small, image.Pt(sw, sh), 0, 0, gocv.InterpolationArea); err != nil {
+		return nil, fmt.Errorf("縮小エラー: %v", err)
+	}
+	if err := d.mog2.Apply(d.small, &d.fg); err != nil {
+		return nil, fmt.Errorf("背景差分エラー: %v", err)
+	}
+	for _, m := range d.cfg.Masks {
+		r := image.Rect(int(float64(m.Min.X)*scale), int(float64(m.Min.Y)*scale),
+			int(float64(m.Max.X)*scale+0.5), int(float64(m.Max.Y)*scale+0.5))
+		gocv.Rectangle(&d.fg, r, color.RGBA{}, -1)
+	}
+	// 小さなノイズを除去してから近接する領域をつなげる
+	if err := gocv.MorphologyEx(d.fg, &d.fg, gocv.MorphOpen, d.kernel); err != nil {
+		return nil, fmt.Errorf("ノイズ除去エラー: %v", err)
+	}
+	if err := gocv.Dilate(d.fg, &d.fg, d.kernel); err != nil {
+		return nil, fmt.Errorf("膨張処理エラー: %v", err)
+	}
+
+	contours := gocv.FindContours(d.fg, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+	minArea := d.cfg.MinArea * float64(sw*sh)
+	var boxes []image.Rectangle
+	for i := 0; i < contours.Size(); i++ {
+		c := contours.At(i)
+		if gocv.ContourArea(c) < minArea {
+			continue
+		}
+		r := gocv.BoundingRect(c)
+		boxes = append(boxes, image.Rect(int(float64(r.Min.X)/scale), int(float64(r.Min.Y)/scale),
+			int(float64(r.Max.X)/scale), int(float64(r.Max.Y)/scale)).Intersect(image.Rect(0, 0, w, h)))
+	}
+
+	now := time.Now()
+	if len(boxes) > 0 {
+		d.lastMotion = now
+		d.lastBoxes = boxes
+		if !d.active {
+			d.active = true
+			d.emit(MotionEvent{Type: MotionStart, Time: now, Frame: d.frame, Boxes: boxes})
+		}
+	} else if d.active && now.Sub(d.lastMotion) >= d.cfg.Cooldown {
+		d.active = false
+		d.emit(MotionEvent{Type: MotionStop, Time: now, Frame: d.frame, Boxes: d.lastBoxes})
+	}
+	return boxes, nil
+}
+
+func (d *MotionDetector) emit(e MotionEvent) {
+	if d.OnEvent != nil {
+		d.OnEvent(e)
+	}
+}
+
+// Active reports whether motion is currently in progress (including the
+// cooldown period).
+func (d *MotionDetector) Active() bool { return d.active }
+
+// Close releases the OpenCV resources.
+func (d *MotionDetector) Close() {
+	d.mog2.Close()
+	d.kernel.Close()
+	d.small.Close()
+	d.fg.Close()
+}
+
+// NewMotionLogger returns an OnEvent handler that writes each event to w as
+// one JSON line.
+func NewMotionLogger(w io.Writer) func(MotionEvent) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e MotionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	}
+}
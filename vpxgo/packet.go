@@ -0,0 +1,24 @@
+package vpxgo
+
+import "github.com/xlab/libvpx-go/vpx"
+
+// Packet is one compressed frame produced by the encoder, with its metadata.
+type Packet struct {
+	Data      []byte
+	PTS       int64
+	Duration  uint64
+	Keyframe  bool
+	Droppable bool
+
+	// Flags はこのフレームのエンコードに使ったフレーム単位フラグ
+	Flags vpx.EncFrameFlags
+
+	// テンポラルレイヤー情報 (TemporalLayers 未使用時はすべてゼロ値)
+	TemporalLayerID int
+	TL0PicIdx       uint8
+	LayerSync       bool // 下位レイヤーのみを参照し、このレイヤーへ切り替え可能
+
+	// VP9 SVC の空間レイヤー情報。スーパーフレームの最後のレイヤーで EndOfSuperframe が true
+	SpatialLayerID  int
+	EndOfSuperframe bool
+}
@@ -0,0 +1,216 @@
+package vpxgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+)
+
+// RawI420Reader reads headerless 8-bit I420 frames (Y, U and V planes of
+// every frame back to back, as written by `ffmpeg -f rawvideo -pix_fmt
+// yuv420p`). The size and frame rate are not part of the stream and must be
+// given by the caller.
+type RawI420Reader struct {
+	r      *bufio.Reader
+	width  int
+	height int
+	fps    float64
+}
+
+// NewRawI420Reader reads frames of width x height at fps from r.
+func NewRawI420Reader(r io.Reader, width, height int, fps float64) (*RawI420Reader, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("生の I420 入力にはサイズの指定が必要です: %dx%d", width, height)
+	}
+	if fps <= 0 {
+		return nil, fmt.Errorf("フレームレートが不正です: %v", fps)
+	}
+	return &RawI420Reader{r: bufio.NewReaderSize(r, 1<<20), width: width, height: height, fps: fps}, nil
+}
+
+// FPS returns the frame rate given to NewRawI420Reader.
+func (r *RawI420Reader) FPS() float64 { return r.fps }
+
+// ReadFrame returns the next frame, or io.EOF when the stream ends on a frame
+// boundary.
+func (r *RawI420Reader) ReadFrame() (*I420Frame, error) {
+	f := NewI420Frame(r.width, r.height)
+	for i, plane := range [][]byte{f.Y, f.U, f.V} {
+		if _, err := io.ReadFull(r.r, plane); err != nil {
+			if err == io.EOF && i == 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("I420 フレームデータ読み込みエラー: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// NewStreamFrameSource reads Y4M from r when the stream starts with a Y4M
+// header and raw I420 of width x height at fps otherwise. For Y4M the header
+// values take precedence.
+func NewStreamFrameSource(r io.Reader, width, height int, fps float64) (FrameSource, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	// 空の入力は Peek がエラーになるので、判定できない場合は生の I420 として扱う
+	if magic, _ := br.Peek(9); string(magic) == "YUV4MPEG2" {
+		return NewY4MReader(br)
+	}
+	return NewRawI420Reader(br, width, height, fps)
+}
+
+// StreamEncodeOptions controls EncodeStream. Only single-pass encoding is
+// possible because the input cannot be read twice, so Passes, Pass and
+// StatsPath of the embedded options are ignored.
+type StreamEncodeOptions struct {
+	FileEncodeOptions
+
+	// Width, Height, FPS は生の I420 入力の場合に必要 (Y4M ではヘッダの値を使う)
+	Width  int
+	Height int
+	FPS    float64
+	// Format は出力コンテナ "ivf" (既定) または "webm"
+	Format string
+}
+
+// EncodeStream encodes the Y4M or raw I420 stream r into an IVF or WebM
+// stream on w, so that the encoder can sit in a Unix pipeline. w is written
+// sequentially: WebM is written with unknown sizes and the IVF frame count
+// stays 0, which players and demuxers accept. Progress goes to logw.
+func EncodeStream(r io.Reader, w io.Writer, opts StreamEncodeOptions, logw io.Writer) error {
+	if opts.Format == "" {
+		opts.Format = "ivf"
+	}
+	src, err := NewStreamFrameSource(r, opts.Width, opts.Height, opts.FPS)
+	if err != nil {
+		return err
+	}
+
+	// bufio.Writer は Seek を持たないので、w が *os.File のパイプでもシークしない
+	bw := bufio.NewWriterSize(w, 1<<16)
+	create := func(codec Codec, width, height int, fps float64) (PacketWriter, io.Closer, error) {
+		pw, err := NewPacketWriter(bw, opts.Format, codec, width, height, fps)
+		return pw, nopCloser{}, err
+	}
+	if _, err := encodeSource(src, create, opts.FileEncodeOptions, 0, nil, logw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("出力書き込みエラー: %v", err)
+	}
+	return nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// Y4MWriter writes 8-bit 4:2:0 frames as a YUV4MPEG2 stream.
+type Y4MWriter struct {
+	w      io.Writer
+	width  int
+	height int
+}
+
+// NewY4MWriter writes the stream header. fps is stored as a fraction with a
+// denominator of 1 or, for non-integer rates such as 29.97, 1000.
+func NewY4MWriter(w io.Writer, width, height int, fps float64) (*Y4MWriter, error) {
+	num, den := int(math.Round(fps)), 1
+	if math.Abs(fps-float64(num)) > 1e-6 {
+		num, den = int(math.Round(fps*1000)), 1000
+	}
+	if _, err := fmt.Fprintf(w, "YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C420jpeg\n", width, height, num, den); err != nil {
+		return nil, fmt.Errorf("Y4M ヘッダ書き込みエラー: %v", err)
+	}
+	return &Y4MWriter{w: w, width: width, height: height}, nil
+}
+
+// WriteFrame appends one frame, which must have the size given in the header.
+func (y *Y4MWriter) WriteFrame(f *I420Frame) error {
+	if f.Width != y.width || f.Height != y.height {
+		return fmt.Errorf("Y4M ではフレームサイズを変更できません: %dx%d -> %dx%d", y.width, y.height, f.Width, f.Height)
+	}
+	if _, err := io.WriteString(y.w, "FRAME\n"); err != nil {
+		return fmt.Errorf("Y4M 書き込みエラー: %v", err)
+	}
+	return WriteI420(y.w, f)
+}
+
+// WriteI420 writes the planes of f without any header (raw I420).
+func WriteI420(w io.Writer, f *I420Frame) error {
+	for _, plane := range [][]byte{f.Y, f.U, f.V} {
+		if _, err := w.Write(plane); err != nil {
+			return fmt.Errorf("I420 書き込みエラー: %v", err)
+		}
+	}
+	return nil
+}
+
+// StreamDecodeOptions controls DecodeStream.
+type StreamDecodeOptions struct {
+	// Format は出力形式 "y4m" (既定) または "i420" (ヘッダなしの生データ)
+	Format string
+	// FPS は Y4M ヘッダに書くフレームレート (既定 30)。コンテナからは取得しない。
+	FPS float64
+}
+
+// DecodeStream decodes the IVF or WebM stream r and writes the frames to w as
+// Y4M or raw I420, the reverse of EncodeStream. It returns the number of
+// frames written.
+func DecodeStream(r io.Reader, w io.Writer, opts StreamDecodeOptions) (int, error) {
+	if opts.Format == "" {
+		opts.Format = "y4m"
+	}
+	if opts.Format != "y4m" && opts.Format != "i420" {
+		return 0, fmt.Errorf("未対応の出力形式です: %q (y4m または i420)", opts.Format)
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = 30
+	}
+	pr, err := NewPacketReader(r)
+	if err != nil {
+		return 0, err
+	}
+	dec, err := NewDecoder(pr.Info().Codec)
+	if err != nil {
+		return 0, err
+	}
+	defer dec.Close()
+
+	bw := bufio.NewWriterSize(w, 1<<20)
+	var y4m *Y4MWriter
+	frames := 0
+	for {
+		p, err := pr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return frames, err
+		}
+		decoded, err := dec.Decode(p.Data)
+		if err != nil {
+			return frames, err
+		}
+		for _, f := range decoded {
+			if opts.Format == "i420" {
+				err = WriteI420(bw, f)
+			} else {
+				if y4m == nil {
+					if y4m, err = NewY4MWriter(bw, f.Width, f.Height, opts.FPS); err != nil {
+						return frames, err
+					}
+				}
+				err = y4m.WriteFrame(f)
+			}
+			if err != nil {
+				return frames, err
+			}
+			frames++
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return frames, fmt.Errorf("出力書き込みエラー: %v", err)
+	}
+	return frames, nil
+}
@@ -0,0 +1,238 @@
+package vpxgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PreRollBuffer keeps the most recent encoded packets in memory so that a
+// recording can start a few seconds before the event that triggered it. The
+// buffer always begins with a keyframe: whole GOPs are evicted from the front
+// once the remaining packets still cover the requested duration, or when the
+// byte limit is exceeded. It is not safe for concurrent use.
+type PreRollBuffer struct {
+	frames   int64 // 保持するフレーム数 (PTS 単位)
+	maxBytes int64
+
+	packets []Packet
+	bytes   int64
+}
+
+// NewPreRollBuffer keeps at least d of packets at fps, using at most maxBytes
+// of packet data (0 = unbounded).
+func NewPreRollBuffer(d time.Duration, fps int, maxBytes int64) *PreRollBuffer {
+	return &PreRollBuffer{frames: int64(d * time.Duration(fps) / time.Second), maxBytes: maxBytes}
+}
+
+// Add appends p. Packets are only kept from the first keyframe on.
+func (b *PreRollBuffer) Add(p Packet) {
+	if len(b.packets) == 0 && !p.Keyframe {
+		return
+	}
+	b.packets = append(b.packets, p)
+	b.bytes += int64(len(p.Data))
+
+	// 次の GOP だけでプリロール長を満たせるなら先頭の GOP を捨てる
+	for {
+		k := b.nextKeyframe()
+		if k < 0 || p.PTS-b.packets[k].PTS < b.frames {
+			break
+		}
+		b.evict(k)
+	}
+	// メモリ上限を超えたら長さに関係なく古い GOP から捨てる
+	for b.maxBytes > 0 && b.bytes > b.maxBytes {
+		k := b.nextKeyframe()
+		if k < 0 {
+			k = len(b.packets) // GOP が 1 つしかなければ次のキーフレームまで空にする
+		}
+		b.evict(k)
+	}
+}
+
+// nextKeyframe は先頭以外で最初のキーフレームの位置を返します (なければ -1)。
+func (b *PreRollBuffer) nextKeyframe() int {
+	for i := 1; i < len(b.packets); i++ {
+		if b.packets[i].Keyframe {
+			return i
+		}
+	}
+	return -1
+}
+
+// evict は先頭の n パケットを捨てます。
+func (b *PreRollBuffer) evict(n int) {
+	for _, p := range b.packets[:n] {
+		b.bytes -= int64(len(p.Data))
+	}
+	b.packets = append(b.packets[:0], b.packets[n:]...)
+}
+
+// Packets returns a copy of the buffered packets, oldest (a keyframe) first.
+func (b *PreRollBuffer) Packets() []Packet {
+	return append([]Packet(nil), b.packets...)
+}
+
+// Bytes returns the amount of buffered packet data.
+func (b *PreRollBuffer) Bytes() int64 { return b.bytes }
+
+// Duration returns the time span covered by the buffer.
+func (b *PreRollBuffer) Duration(fps int) time.Duration {
+	if len(b.packets) == 0 {
+		return 0
+	}
+	n := b.packets[len(b.packets)-1].PTS - b.packets[0].PTS + 1
+	return time.Duration(n) * time.Second / time.Duration(fps)
+}
+
+// EventRecorderConfig configures an EventRecorder.
+type EventRecorderConfig struct {
+	Dir    string
+	Prefix string // ファイル名の接頭辞 (既定 "event")
+	Ext    string // ".webm" (既定) または ".ivf"
+
+	PreRoll  time.Duration // イベント前に含める長さ
+	PostRoll time.Duration // 最後のイベントから録画を続ける長さ
+	// MaxBufferBytes はプリロールバッファのメモリ上限 (0 は無制限)
+	MaxBufferBytes int64
+}
+
+// EventRecorder records files around events. The last PreRoll of the stream
+// is kept in a PreRollBuffer; Trigger starts a file with that pre-roll and
+// recording continues until PostRoll after the last trigger.
+type EventRecorder struct {
+	cfg    EventRecorderConfig
+	codec  Codec
+	width  int
+	height int
+	fps    int
+
+	// RequestKeyframe is called when an event fires before any keyframe is
+	// buffered, typically Encoder.RequestKeyframe.
+	RequestKeyframe func()
+	// OnFileClosed is called with the path of each finished recording.
+	OnFileClosed func(path string)
+
+	mu     sync.Mutex
+	buf    *PreRollBuffer
+	cur    *recordingFile
+	stopAt int64 // この PTS を超えたら録画を終える
+	lastPT int64
+}
+
+// NewEventRecorder creates the output directory and the pre-roll buffer.
+func NewEventRecorder(cfg EventRecorderConfig, codec Codec, width, height, fps int) (*EventRecorder, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "event"
+	}
+	var err error
+	if cfg.Ext, err = prepareRecordDir(cfg.Dir, cfg.Ext); err != nil {
+		return nil, err
+	}
+	return &EventRecorder{
+		cfg:    cfg,
+		codec:  codec,
+		width:  width,
+		height: height,
+		fps:    fps,
+		buf:    NewPreRollBuffer(cfg.PreRoll, fps, cfg.MaxBufferBytes),
+		lastPT: -1,
+	}, nil
+}
+
+// WritePacket feeds one packet into the pre-roll buffer and, while an event
+// is active, into the current recording.
+func (r *EventRecorder) WritePacket(p Packet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf.Add(p)
+	r.lastPT = p.PTS
+	if r.cur == nil {
+		return nil
+	}
+	if p.PTS > r.stopAt {
+		return r.finish()
+	}
+	if r.cur.base < 0 && !p.Keyframe {
+		return nil // プリロールが無かった場合は最初のキーフレームから書く
+	}
+	return r.cur.write(p)
+}
+
+// Trigger starts a recording (with the buffered pre-roll) or, when one is
+// already running, extends it by PostRoll from now.
+func (r *EventRecorder) Trigger() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopAt = r.lastPT + int64(r.cfg.PostRoll*time.Duration(r.fps)/time.Second)
+	if r.cur != nil {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%s%s", r.cfg.Prefix, time.Now().Format(recordTimeFormat), r.cfg.Ext)
+	f, err := createRecordingFile(uniqueFilePath(r.cfg.Dir, name, r.cfg.Ext), r.codec, r.width, r.height, r.fps)
+	if err != nil {
+		return err
+	}
+	r.cur = f
+	pre := r.buf.Packets()
+	if len(pre) == 0 && r.RequestKeyframe != nil {
+		r.RequestKeyframe()
+	}
+	for _, p := range pre {
+		if err := f.write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recording reports whether an event recording is in progress.
+func (r *EventRecorder) Recording() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cur != nil
+}
+
+// ServeHTTP triggers an event on POST and reports the recording state as
+// JSON, so that external systems can start recordings.
+func (r *EventRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		if err := r.Trigger(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if req.Method != http.MethodGet {
+		http.Error(w, "GET または POST を使用してください", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"recording": r.Recording()})
+}
+
+// Close finishes the current recording, if any.
+func (r *EventRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cur == nil {
+		return nil
+	}
+	return r.finish()
+}
+
+func (r *EventRecorder) finish() error {
+	f := r.cur
+	r.cur = nil
+	if err := f.close(); err != nil {
+		return fmt.Errorf("録画ファイルのクローズエラー: %v", err)
+	}
+	if r.OnFileClosed != nil {
+		r.OnFileClosed(f.path)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package vpxgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// prerollPackets は PTS from..to-1 の 10 バイトのパケットを作ります (gop フレームごとにキーフレーム)。
+func prerollPackets(from, to, gop int) []Packet {
+	var pkts []Packet
+	for i := from; i < to; i++ {
+		pkts = append(pkts, Packet{Data: make([]byte, 10), PTS: int64(i), Duration: 1, Keyframe: i%gop == 0})
+	}
+	return pkts
+}
+
+func TestPreRollBufferKeepsWholeGOPs(t *testing.T) {
+	b := NewPreRollBuffer(2*time.Second, 10, 0)
+	for _, p := range prerollPackets(0, 50, 10) {
+		b.Add(p)
+	}
+	// 最後のフレームから 2 秒 (20 フレーム) 以上を含む、最も新しいキーフレームから残る
+	pkts := b.Packets()
+	if len(pkts) != 30 || pkts[0].PTS != 20 || !pkts[0].Keyframe {
+		t.Fatalf("%d パケット、先頭 PTS %d", len(pkts), pkts[0].PTS)
+	}
+	if d := b.Duration(10); d != 3*time.Second {
+		t.Errorf("Duration = %v, want 3s", d)
+	}
+	if b.Bytes() != 300 {
+		t.Errorf("Bytes = %d, want 300", b.Bytes())
+	}
+}
+
+func TestPreRollBufferStartsAtKeyframe(t *testing.T) {
+	b := NewPreRollBuffer(time.Second, 10, 0)
+	for _, p := range prerollPackets(3, 12, 5) {
+		b.Add(p)
+	}
+	if pkts := b.Packets(); len(pkts) != 7 || pkts[0].PTS != 5 {
+		t.Fatalf("キーフレームより前のパケットが残っています: %d パケット", len(pkts))
+	}
+}
+
+func TestPreRollBufferByteLimit(t *testing.T) {
+	b := NewPreRollBuffer(2*time.Second, 10, 150)
+	for _, p := range prerollPackets(0, 50, 10) {
+		b.Add(p)
+	}
+	// 上限に収まるまで長さに関係なく古い GOP を捨てる
+	if pkts := b.Packets(); len(pkts) != 10 || pkts[0].PTS != 40 || b.Bytes() != 100 {
+		t.Fatalf("%d パケット、先頭 PTS %d、%d バイト", len(pkts), pkts[0].PTS, b.Bytes())
+	}
+
+	// GOP 1 つで上限を超える場合は次のキーフレームまで空にする
+	b = NewPreRollBuffer(2*time.Second, 10, 50)
+	for _, p := range prerollPackets(0, 10, 10) {
+		b.Add(p)
+	}
+	if len(b.Packets()) != 0 || b.Bytes() != 0 {
+		t.Fatalf("%d パケット、%d バイト", len(b.Packets()), b.Bytes())
+	}
+}
+
+func TestEventRecorder(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewEventRecorder(EventRecorderConfig{Dir: dir, PreRoll: time.Second, PostRoll: time.Second}, CodecVP8, 64, 48, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closed []string
+	r.OnFileClosed = func(path string) { closed = append(closed, path) }
+
+	write := func(from, to int) {
+		for _, p := range prerollPackets(from, to, 5) {
+			if err := r.WritePacket(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(3, 18)
+	if err := r.Trigger(); err != nil {
+		t.Fatal(err)
+	}
+	write(18, 21)
+	// 録画中の再トリガーはポストロールを PTS 20 から 1 秒に延ばす
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/event", nil))
+	if !strings.Contains(rec.Body.String(), `"recording":true`) {
+		t.Errorf("状態 %s", rec.Body.String())
+	}
+	write(21, 40)
+	if r.Recording() || len(closed) != 1 {
+		t.Fatalf("録画が終わっていません: recording=%v closed=%v", r.Recording(), closed)
+	}
+
+	f, err := os.Open(closed[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	wr, err := NewWebMReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ContainerPacket
+	for {
+		p, err := wr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	// プリロールは最初のキーフレーム (PTS 5) から、ポストロールは PTS 30 まで
+	if len(got) != 26 || !got[0].Keyframe || got[0].Timestamp != 0 {
+		t.Fatalf("%d パケット、先頭 %+v", len(got), got[0])
+	}
+	if last := got[len(got)-1].Timestamp; last != 2500*time.Millisecond {
+		t.Errorf("最後のパケットの時刻 %v, want 2.5s", last)
+	}
+}
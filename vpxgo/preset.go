@@ -0,0 +1,95 @@
+package vpxgo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// presets は用途ごとの推奨設定です。解像度は含まず、呼び出し側で決めます。
+var presets = map[string]func() EncoderConfig{
+	// 低遅延の通話: ロス耐性を優先し、キーフレームの急増を抑える
+	"call": func() EncoderConfig {
+		return EncoderConfig{
+			Codec:           CodecVP8,
+			FPS:             30,
+			BitrateKbps:     800,
+			ErrorResilient:  true,
+			TokenPartitions: 2,
+			Controls: CodecControls{
+				CpuUsed:            intp(-6),
+				NoiseSensitivity:   intp(1),
+				StaticThreshold:    intp(1),
+				MaxIntraBitratePct: intp(300),
+			},
+		}
+	},
+	// 画面共有: 静止部分が多く文字の鮮明さが重要。時間レイヤーで受信側が間引けるようにする
+	"screenshare": func() EncoderConfig {
+		return EncoderConfig{
+			Codec:          CodecVP8,
+			FPS:            15,
+			BitrateKbps:    1200,
+			ErrorResilient: true,
+			TemporalLayers: TemporalLayerConfig{Layers: 2},
+			Controls: CodecControls{
+				CpuUsed:            intp(-8),
+				ScreenContent:      intp(1),
+				StaticThreshold:    intp(100),
+				MaxIntraBitratePct: intp(1000),
+			},
+		}
+	},
+	// 保存用: 速度より画質。先読みで alt-ref を使い、EncodeFile の 2 パスとも組み合わせられる
+	"archive": func() EncoderConfig {
+		return EncoderConfig{
+			Codec:       CodecVP9,
+			FPS:         30,
+			BitrateKbps: 2500,
+			LagInFrames: 25,
+			Controls: CodecControls{
+				CpuUsed:       intp(1),
+				AutoAltRef:    boolp(true),
+				ARNRMaxFrames: intp(7),
+				ARNRStrength:  intp(5),
+			},
+		}
+	},
+	// 監視カメラ: 静止した背景にビットを使わない
+	"surveillance": func() EncoderConfig {
+		return EncoderConfig{
+			Codec:       CodecVP8,
+			FPS:         15,
+			BitrateKbps: 500,
+			Controls: CodecControls{
+				CpuUsed:          intp(-8),
+				NoiseSensitivity: intp(3),
+				StaticThreshold:  intp(500),
+			},
+		}
+	},
+}
+
+// Presets returns the names of the built-in presets.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for n := range presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preset returns the full parameter set of a built-in preset for a
+// width x height source.
+func Preset(name string, width, height int) (EncoderConfig, error) {
+	p, ok := presets[name]
+	if !ok {
+		return EncoderConfig{}, fmt.Errorf("不明なプリセット: %q (%v)", name, Presets())
+	}
+	c := p()
+	c.Width, c.Height = width, height
+	return c, nil
+}
+
+func intp(v int) *int    { return &v }
+func boolp(v bool) *bool { return &v }
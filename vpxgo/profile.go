@@ -0,0 +1,86 @@
+package vpxgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profile はプロファイルファイルの形式です。EncoderConfig の項目に加えて、
+// 土台にするプリセット名を指定できます。
+type profile struct {
+	Preset string `json:"preset"`
+	EncoderConfig
+}
+
+// LoadProfile reads a JSON or YAML profile (chosen by the .yaml/.yml
+// extension) and resolves it against base. A "preset" key replaces base with
+// that preset, keeping base's resolution; the remaining keys override single
+// fields, e.g.
+//
+//	preset: call
+//	bitrate_kbps: 1200
+//	controls:
+//	  cpu_used: -4
+//
+// Unknown keys are rejected. The result is validated.
+func LoadProfile(path string, base EncoderConfig) (EncoderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EncoderConfig{}, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	c, err := ParseProfile(data, ext == ".yaml" || ext == ".yml", base)
+	if err != nil {
+		return EncoderConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ParseProfile is LoadProfile for in-memory data.
+func ParseProfile(data []byte, isYAML bool, base EncoderConfig) (EncoderConfig, error) {
+	if isYAML {
+		// YAML は一度汎用の値に読み込み、JSON と同じ規則 (json タグ) で解釈する
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return EncoderConfig{}, fmt.Errorf("YAML 解析エラー: %v", err)
+		}
+		if v == nil {
+			v = map[string]any{}
+		}
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return EncoderConfig{}, fmt.Errorf("YAML 変換エラー: %v", err)
+		}
+	}
+
+	var head struct {
+		Preset string `json:"preset"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return EncoderConfig{}, fmt.Errorf("プロファイル解析エラー: %v", err)
+	}
+	if head.Preset != "" {
+		p, err := Preset(head.Preset, base.Width, base.Height)
+		if err != nil {
+			return EncoderConfig{}, err
+		}
+		base = p
+	}
+
+	p := profile{EncoderConfig: base}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return EncoderConfig{}, fmt.Errorf("プロファイル解析エラー: %v", err)
+	}
+	if err := p.EncoderConfig.Validate(); err != nil {
+		return EncoderConfig{}, fmt.Errorf("プロファイルの設定が不正です: %w", err)
+	}
+	return p.EncoderConfig, nil
+}
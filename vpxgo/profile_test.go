@@ -0,0 +1,119 @@
+package vpxgo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProfile(t *testing.T) {
+	base := DefaultEncoderConfig(1280, 720)
+	tests := []struct {
+		name    string
+		data    string
+		yaml    bool
+		check   func(t *testing.T, c EncoderConfig)
+		wantErr string // 空なら成功を期待する
+	}{
+		{
+			name: "JSON で個別の項目を上書き",
+			data: `{"bitrate_kbps": 1500, "keyframe_max_dist": 60}`,
+			check: func(t *testing.T, c EncoderConfig) {
+				if c.Codec != CodecVP8 || c.BitrateKbps != 1500 || c.KeyframeMaxDist != 60 || c.FPS != 30 {
+					t.Errorf("%+v", c)
+				}
+			},
+		},
+		{
+			name: "YAML でプリセットを土台にする",
+			data: "preset: call\nbitrate_kbps: 1200\ncontrols:\n  cpu_used: -4\n",
+			yaml: true,
+			check: func(t *testing.T, c EncoderConfig) {
+				if c.BitrateKbps != 1200 || !c.ErrorResilient || c.TokenPartitions != 2 {
+					t.Errorf("%+v", c)
+				}
+				// 指定した制御だけが置き換わり、プリセットの他の制御は残る
+				if c.Controls.CpuUsed == nil || *c.Controls.CpuUsed != -4 {
+					t.Errorf("CpuUsed = %v, want -4", c.Controls.CpuUsed)
+				}
+				if c.Controls.NoiseSensitivity == nil || *c.Controls.NoiseSensitivity != 1 {
+					t.Errorf("NoiseSensitivity = %v, want 1 (プリセットの値)", c.Controls.NoiseSensitivity)
+				}
+			},
+		},
+		{
+			name: "プリセットは解像度を変えず、記述順に関係なく個別の項目が優先",
+			data: `{"bitrate_kbps": 3000, "preset": "archive"}`,
+			check: func(t *testing.T, c EncoderConfig) {
+				if c.Codec != CodecVP9 || c.BitrateKbps != 3000 || c.LagInFrames != 25 {
+					t.Errorf("%+v", c)
+				}
+				if c.Width != 1280 || c.Height != 720 {
+					t.Errorf("解像度 %dx%d, want 1280x720", c.Width, c.Height)
+				}
+			},
+		},
+		{
+			name: "YAML のコーデック名と tune",
+			data: "codec: vp9\ncontrols:\n  tune: ssim\n",
+			yaml: true,
+			check: func(t *testing.T, c EncoderConfig) {
+				if c.Codec != CodecVP9 || c.Controls.Tune == nil || *c.Controls.Tune != TuneSSIM {
+					t.Errorf("%+v", c)
+				}
+			},
+		},
+		{
+			name: "空の YAML は土台のまま",
+			data: "",
+			yaml: true,
+			check: func(t *testing.T, c EncoderConfig) {
+				if c.Codec != base.Codec || c.BitrateKbps != base.BitrateKbps || c.Width != base.Width {
+					t.Errorf("%+v", c)
+				}
+			},
+		},
+		{name: "JSON の未知のキー", data: `{"bitrate": 1000}`, wantErr: "unknown field"},
+		{name: "YAML の未知のキー", data: "bitrate: 1000\n", yaml: true, wantErr: "unknown field"},
+		{name: "入れ子の未知のキー", data: "controls:\n  cpu: 4\n", yaml: true, wantErr: "unknown field"},
+		{name: "未知のプリセット", data: `{"preset": "nope"}`, wantErr: "nope"},
+		{name: "未対応のコーデック", data: `{"codec": "h264"}`, wantErr: "未対応のコーデック"},
+		{name: "YAML の構文エラー", data: "bitrate_kbps: [1\n", yaml: true, wantErr: "YAML 解析エラー"},
+		{name: "範囲外の値", data: `{"token_partitions": 4}`, wantErr: "TokenPartitions"},
+		{name: "コーデックと合わない制御", data: "preset: archive\ncontrols:\n  cpu_used: 12\n", yaml: true, wantErr: "cpu-used"},
+		{name: "VP9 に時間レイヤー", data: `{"preset": "screenshare", "codec": "vp9"}`, wantErr: "VP8 専用"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseProfile([]byte(tt.data), tt.yaml, base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q を含むエラー", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestPresets(t *testing.T) {
+	for _, name := range Presets() {
+		c, err := Preset(name, 640, 360)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if c.Width != 640 || c.Height != 360 {
+			t.Errorf("%s: 解像度 %dx%d", name, c.Width, c.Height)
+		}
+		if err := c.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := Preset("nope", 640, 360); err == nil {
+		t.Error("未知のプリセットでエラーになりません")
+	}
+}
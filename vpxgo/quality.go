@@ -0,0 +1,64 @@
+package vpxgo
+
+import "math"
+
+// ssimPlane returns the mean SSIM of two 8-bit planes of the same size, using
+// 8x8 windows on a 4-pixel grid as libvpx does.
+func ssimPlane(a []byte, aStride int, b []byte, bStride int, width, height int) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	var total float64
+	windows := 0
+	for y := 0; y+8 <= height; y += 4 {
+		for x := 0; x+8 <= width; x += 4 {
+			var sa, sb, saa, sbb, sab float64
+			for j := 0; j < 8; j++ {
+				ra := a[(y+j)*aStride+x:]
+				rb := b[(y+j)*bStride+x:]
+				for i := 0; i < 8; i++ {
+					va, vb := float64(ra[i]), float64(rb[i])
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+			const n = 64.0
+			ma, mb := sa/n, sb/n
+			va := saa/n - ma*ma
+			vb := sbb/n - mb*mb
+			cov := sab/n - ma*mb
+			total += ((2*ma*mb + c1) * (2*cov + c2)) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+// planeSSE returns the sum of squared errors between two tightly packed planes.
+func planeSSE(a, b []byte) uint64 {
+	var sse uint64
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		sse += uint64(d * d)
+	}
+	return sse
+}
+
+// psnrFromSSE converts an SSE over samples 8-bit values into PSNR (dB).
+// Identical planes are reported as 100 dB, following libvpx.
+func psnrFromSSE(sse uint64, samples int) float64 {
+	const maxPSNR = 100
+	if sse == 0 || samples == 0 {
+		return maxPSNR
+	}
+	mse := float64(sse) / float64(samples)
+	return math.Min(10*math.Log10(255*255/mse), maxPSNR)
+}
@@ -0,0 +1,276 @@
+package vpxgo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordTimeFormat はセグメントのファイル名に使う開始時刻の形式です (辞書順 = 時刻順)。
+const recordTimeFormat = "20060102-150405"
+
+// RecorderConfig configures a SegmentRecorder.
+type RecorderConfig struct {
+	Dir    string
+	Prefix string // ファイル名の接頭辞 (既定 "rec")
+	Ext    string // ".webm" (既定) または ".ivf"
+
+	// MaxDuration と MaxBytes のどちらかに達したら次のキーフレームでファイルを切り替える (0 は無制限)
+	MaxDuration time.Duration
+	MaxBytes    int64
+	// DiskBudget を超えたら古いセグメントから削除する (0 は削除しない)
+	DiskBudget int64
+}
+
+// recordingFile はタイムスタンプを 0 から始め直して 1 つのファイルに書き込みます。
+type recordingFile struct {
+	path  string
+	pw    PacketWriter
+	f     io.Closer
+	base  int64 // 最初のパケットの PTS
+	last  int64
+	bytes int64
+}
+
+func createRecordingFile(path string, codec Codec, width, height, fps int) (*recordingFile, error) {
+	pw, f, err := CreatePacketWriter(path, codec, width, height, float64(fps))
+	if err != nil {
+		return nil, err
+	}
+	return &recordingFile{path: path, pw: pw, f: f, base: -1}, nil
+}
+
+func (r *recordingFile) write(p Packet) error {
+	if r.base < 0 {
+		r.base = p.PTS
+	}
+	r.last = p.PTS
+	p.PTS -= r.base
+	r.bytes += int64(len(p.Data))
+	return r.pw.WritePacket(p)
+}
+
+// frames は書き込んだフレーム数 (PTS の範囲) を返します。
+func (r *recordingFile) frames() int64 {
+	if r.base < 0 {
+		return 0
+	}
+	return r.last - r.base + 1
+}
+
+func (r *recordingFile) close() error {
+	err := r.pw.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// SegmentRecorder writes a continuous stream to a series of files, each
+// starting with a keyframe and named after its start time. When a segment
+// reaches MaxDuration or MaxBytes a keyframe is requested and the file is cut
+// there. After every cut the oldest segments are removed until the directory
+// fits into DiskBudget.
+type SegmentRecorder struct {
+	cfg    RecorderConfig
+	codec  Codec
+	width  int
+	height int
+	fps    int
+
+	// RequestKeyframe is called when a segment is due to be cut, typically
+	// Encoder.RequestKeyframe.
+	RequestKeyframe func()
+
+	mu        sync.Mutex
+	cur       *recordingFile
+	requested bool
+}
+
+// NewSegmentRecorder creates the output directory and applies defaults.
+func NewSegmentRecorder(cfg RecorderConfig, codec Codec, width, height, fps int) (*SegmentRecorder, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "rec"
+	}
+	var err error
+	if cfg.Ext, err = prepareRecordDir(cfg.Dir, cfg.Ext); err != nil {
+		return nil, err
+	}
+	return &SegmentRecorder{cfg: cfg, codec: codec, width: width, height: height, fps: fps}, nil
+}
+
+// WritePacket records one packet. Packets before the first keyframe are
+// dropped.
+func (s *SegmentRecorder) WritePacket(p Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cur != nil && s.due() {
+		if p.Keyframe {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		} else if !s.requested && s.RequestKeyframe != nil {
+			s.RequestKeyframe()
+			s.requested = true
+		}
+	}
+	if s.cur == nil {
+		if !p.Keyframe {
+			return nil
+		}
+		name := fmt.Sprintf("%s-%s%s", s.cfg.Prefix, time.Now().Format(recordTimeFormat), s.cfg.Ext)
+		f, err := createRecordingFile(uniqueFilePath(s.cfg.Dir, name, s.cfg.Ext), s.codec, s.width, s.height, s.fps)
+		if err != nil {
+			return err
+		}
+		s.cur = f
+	}
+	return s.cur.write(p)
+}
+
+// due は現在のセグメントが切り替え条件に達したかを返します。
+func (s *SegmentRecorder) due() bool {
+	if s.cfg.MaxDuration > 0 && time.Duration(s.cur.frames())*time.Second/time.Duration(s.fps) >= s.cfg.MaxDuration {
+		return true
+	}
+	return s.cfg.MaxBytes > 0 && s.cur.bytes >= s.cfg.MaxBytes
+}
+
+// rotate は現在のファイルを閉じ、保持ポリシーを適用します。次のファイルは呼び出し側で開く。
+func (s *SegmentRecorder) rotate() error {
+	err := s.cur.close()
+	s.cur = nil
+	s.requested = false
+	if err != nil {
+		return fmt.Errorf("録画ファイルのクローズエラー: %v", err)
+	}
+	return s.applyRetention()
+}
+
+// prepareRecordDir は出力先を作成し、拡張子を検証して返します (空なら ".webm")。
+func prepareRecordDir(dir, ext string) (string, error) {
+	if ext == "" {
+		ext = ".webm"
+	}
+	if ext != ".webm" && ext != ".ivf" {
+		return "", fmt.Errorf("録画形式は .webm または .ivf で指定してください: %s", ext)
+	}
+	return ext, os.MkdirAll(dir, 0o755)
+}
+
+// uniqueFilePath は同じ秒に複数のファイルを作る場合に _1, _2 ... の連番を付けます。
+// 連番は既存のファイルの最大値の次にするので、保持ポリシーで古いファイルが消えて
+// いても、削除された名前を再利用して作成順と名前の順序が食い違うことはない。
+func uniqueFilePath(dir, name, ext string) string {
+	base := strings.TrimSuffix(name, ext)
+	next := 0
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		n := e.Name()
+		if n == name {
+			next = max(next, 1)
+		} else if strings.HasPrefix(n, base+"_") && strings.HasSuffix(n, ext) {
+			if seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(n, base+"_"), ext)); err == nil {
+				next = max(next, seq+1)
+			}
+		}
+	}
+	if next == 0 {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, next, ext))
+}
+
+// applyRetention は DiskBudget を超えた分だけ古いセグメントから削除します。
+func (s *SegmentRecorder) applyRetention() error {
+	if s.cfg.DiskBudget <= 0 {
+		return nil
+	}
+	segs, err := RecordedSegments(s.cfg.Dir, s.cfg.Prefix, s.cfg.Ext)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, seg := range segs {
+		total += seg.Size
+	}
+	// 直前に閉じた最新のセグメントは予算を超えていても残す
+	for _, seg := range segs[:max(len(segs)-1, 0)] {
+		if total <= s.cfg.DiskBudget {
+			break
+		}
+		if err := os.Remove(seg.Path); err != nil {
+			return fmt.Errorf("古い録画の削除エラー: %v", err)
+		}
+		total -= seg.Size
+	}
+	return nil
+}
+
+// Close finishes the current segment and applies the retention policy.
+func (s *SegmentRecorder) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cur == nil {
+		return nil
+	}
+	return s.rotate()
+}
+
+// RecordedSegment is one file written by a SegmentRecorder.
+type RecordedSegment struct {
+	Path  string
+	Start time.Time
+	Size  int64
+
+	seq int // 同じ秒に作られたファイルの連番 (uniqueFilePath の _1, _2 ...)
+}
+
+// RecordedSegments lists the segments in dir written with prefix and ext,
+// oldest first. Files started within the same second are ordered by their
+// sequence suffix.
+func RecordedSegments(dir, prefix, ext string) ([]RecordedSegment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []RecordedSegment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix+"-") || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix+"-"), ext)
+		var seq int
+		if i := strings.IndexByte(stamp, '_'); i >= 0 {
+			if seq, err = strconv.Atoi(stamp[i+1:]); err != nil {
+				continue
+			}
+			stamp = stamp[:i]
+		}
+		start, err := time.ParseInLocation(recordTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		segs = append(segs, RecordedSegment{Path: filepath.Join(dir, name), Start: start, Size: info.Size(), seq: seq})
+	}
+	// パスの辞書順では _10 が _2 より前になるので、時刻と連番で並べる
+	sort.Slice(segs, func(i, j int) bool {
+		if !segs[i].Start.Equal(segs[j].Start) {
+			return segs[i].Start.Before(segs[j].Start)
+		}
+		return segs[i].seq < segs[j].seq
+	})
+	return segs, nil
+}
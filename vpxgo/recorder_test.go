@@ -0,0 +1,104 @@
+package vpxgo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordedSegmentsOrder(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"rec-20260102-000000.webm",
+		"rec-20260101-235959_2.webm",
+		"rec-20260101-235959_10.webm",
+		"rec-20260101-235959.webm",
+		"rec-20260101-235959_1.webm",
+		// 対象外
+		"other-20260101-000000.webm",
+		"rec-20260101-000000.ivf",
+		"rec-notatime.webm",
+		"rec-20260101-000000_x.webm",
+	}
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segs, err := RecordedSegments(dir, "rec", ".webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"rec-20260101-235959.webm",
+		"rec-20260101-235959_1.webm",
+		"rec-20260101-235959_2.webm",
+		"rec-20260101-235959_10.webm",
+		"rec-20260102-000000.webm",
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("%d セグメント, want %d: %+v", len(segs), len(want), segs)
+	}
+	for i, s := range segs {
+		if filepath.Base(s.Path) != want[i] {
+			t.Errorf("%d 番目 %s, want %s", i, filepath.Base(s.Path), want[i])
+		}
+	}
+}
+
+func TestSegmentRecorderRotationAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSegmentRecorder(RecorderConfig{Dir: dir, MaxBytes: 40, DiskBudget: 800}, CodecVP8, 64, 48, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requested := 0
+	s.RequestKeyframe = func() { requested++ }
+
+	// 10 バイトのパケット (先頭はフレーム番号)、10 フレームごとにキーフレーム
+	for i := 0; i < 60; i++ {
+		p := Packet{Data: make([]byte, 10), PTS: int64(i), Duration: 1, Keyframe: i%10 == 0}
+		p.Data[0] = byte(i)
+		if err := s.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segs, err := RecordedSegments(dir, "rec", ".webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// キーフレームごとに 6 ファイルに分かれ、予算に収まるよう古いものから消える
+	var total int64
+	for _, seg := range segs {
+		total += seg.Size
+	}
+	if len(segs) != 2 || total > 800 {
+		t.Fatalf("%d セグメント, 合計 %d バイト", len(segs), total)
+	}
+	// 各セグメントは 4 パケット目で上限に達し、1 回だけキーフレームを要求する
+	if requested != 6 {
+		t.Errorf("キーフレーム要求 %d 回, want 6", requested)
+	}
+
+	// 最新の 2 つが残り、どれも先頭のキーフレームから再生できる
+	for i, seg := range segs {
+		f, err := os.Open(seg.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewWebMReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := r.ReadPacket()
+		f.Close()
+		if err != nil || !p.Keyframe || p.Timestamp != 0 || p.Data[0] != byte(40+i*10) {
+			t.Errorf("%s: 先頭パケット %+v, err %v", seg.Path, p, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package vpxgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// LossRecovery chooses per-frame reference flags so that a reported loss is
+// repaired by predicting from a long-term reference the receiver has
+// acknowledged, falling back to a keyframe only when none exists.
+//
+// GOLDEN と ALTREF の 2 つのバッファを長期参照に使います。通常フレームはどちらも
+// 更新せず、GoldenInterval ごとに新しいフレームを「最新の確認済み参照を持たない方」の
+// バッファに入れるので、新しい参照が確認されるまで古い確認済み参照が残ります。
+type LossRecovery struct {
+	GoldenInterval int
+
+	mu           sync.Mutex
+	refs         [2]longTermRef // 0: GOLDEN, 1: ALTREF
+	sinceRefresh int
+	lossPending  bool
+}
+
+// longTermRef は長期参照バッファに入っているフレームです。
+type longTermRef struct {
+	pts   int64 // -1 は空
+	acked bool
+}
+
+var (
+	longTermForce = [2]vpx.EncFrameFlags{vpxext.EflagForceGf, vpxext.EflagForceArf}
+	longTermNoRef = [2]vpx.EncFrameFlags{vpxext.EflagNoRefGf, vpxext.EflagNoRefArf}
+	longTermNoUpd = [2]vpx.EncFrameFlags{vpxext.EflagNoUpdGf, vpxext.EflagNoUpdArf}
+)
+
+// NewLossRecovery returns a strategy that refreshes a long-term reference at
+// most every goldenInterval frames.
+func NewLossRecovery(goldenInterval int) *LossRecovery {
+	return &LossRecovery{GoldenInterval: goldenInterval, refs: [2]longTermRef{{pts: -1}, {pts: -1}}}
+}
+
+// Ack records that the receiver decoded the frame with the given pts (e.g. from
+// an RTCP RPSI message). Only acks of frames held as long-term references
+// matter.
+func (r *LossRecovery) Ack(pts int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.refs {
+		if r.refs[i].pts == pts {
+			r.refs[i].acked = true
+		}
+	}
+}
+
+// ReportLoss requests that the next frame be encoded so that the receiver can
+// resume decoding (e.g. on RTCP PLI or an unrecoverable NACK).
+func (r *LossRecovery) ReportLoss() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lossPending = true
+}
+
+// newestAcked は確認済みで最も新しい参照のバッファ番号を返します (なければ -1)。
+func (r *LossRecovery) newestAcked() int {
+	best := -1
+	for i, ref := range r.refs {
+		if ref.acked && (best < 0 || ref.pts > r.refs[best].pts) {
+			best = i
+		}
+	}
+	return best
+}
+
+// NextFlags returns the reference flags for the frame about to be encoded.
+func (r *LossRecovery) NextFlags() vpx.EncFrameFlags {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keep := r.newestAcked()
+	if r.lossPending {
+		r.lossPending = false
+		if keep < 0 {
+			return vpx.EflagForceKf
+		}
+		// 確認済みの参照だけを参照して復帰する
+		return vpxext.EflagNoRefLast | longTermNoRef[1-keep] | vpxext.EflagNoUpdGf | vpxext.EflagNoUpdArf
+	}
+
+	r.sinceRefresh++
+	if keep >= 0 && r.GoldenInterval > 0 && r.sinceRefresh >= r.GoldenInterval {
+		// 確認済みの参照は残し、もう一方を新しいフレームで置き換える
+		return longTermForce[1-keep] | longTermNoUpd[keep]
+	}
+	// 復帰用の参照を上書きしないよう固定する
+	return vpxext.EflagNoUpdGf | vpxext.EflagNoUpdArf
+}
+
+// OnPacket updates the long-term reference bookkeeping from an encoded packet.
+func (r *LossRecovery) OnPacket(p Packet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.Keyframe {
+		// キーフレームはすべての参照バッファを更新する
+		r.refs = [2]longTermRef{{pts: p.PTS}, {pts: p.PTS}}
+		r.sinceRefresh = 0
+		return
+	}
+	for i, f := range longTermForce {
+		if p.Flags&f != 0 {
+			r.refs[i] = longTermRef{pts: p.PTS}
+			r.sinceRefresh = 0
+		}
+	}
+}
+
+// ServeHTTP accepts receiver feedback so that the strategy can be driven
+// without an RTCP stack: POST ?ack=<pts> acknowledges a frame and POST
+// ?loss=1 reports a loss. GET reports the long-term references as JSON.
+func (r *LossRecovery) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		if s := req.URL.Query().Get("ack"); s != "" {
+			pts, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				http.Error(w, "ack には pts を整数で指定してください", http.StatusBadRequest)
+				return
+			}
+			r.Ack(pts)
+		}
+		if req.URL.Query().Get("loss") != "" {
+			r.ReportLoss()
+		}
+	} else if req.Method != http.MethodGet {
+		http.Error(w, "GET または POST を使用してください", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.mu.Lock()
+	type ref struct {
+		PTS   int64 `json:"pts"`
+		Acked bool  `json:"acked"`
+	}
+	state := map[string]ref{
+		"golden": {r.refs[0].pts, r.refs[0].acked},
+		"altref": {r.refs[1].pts, r.refs[1].acked},
+	}
+	r.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,77 @@
+package vpxgo
+
+import (
+	"testing"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// encodeRecovery は NextFlags の結果でフレーム pts をエンコードしたことにします。
+func encodeRecovery(r *LossRecovery, pts int64, keyframe bool) vpx.EncFrameFlags {
+	flags := r.NextFlags()
+	r.OnPacket(Packet{PTS: pts, Flags: flags, Keyframe: keyframe || flags&vpx.EflagForceKf != 0})
+	return flags
+}
+
+func TestLossRecoveryKeepsAckedReference(t *testing.T) {
+	r := NewLossRecovery(3)
+
+	// 確認済みの参照がなければキーフレームで復帰する
+	r.ReportLoss()
+	if f := encodeRecovery(r, 0, false); f&vpx.EflagForceKf == 0 {
+		t.Fatalf("確認前の損失: flags %#x, キーフレームが必要", f)
+	}
+	r.Ack(0)
+
+	// 通常フレームは長期参照を更新しない
+	for pts := int64(1); pts < 3; pts++ {
+		if f := encodeRecovery(r, pts, false); f&(vpxext.EflagNoUpdGf|vpxext.EflagNoUpdArf) != vpxext.EflagNoUpdGf|vpxext.EflagNoUpdArf {
+			t.Fatalf("pts %d: flags %#x", pts, f)
+		}
+	}
+	// 間隔に達したら確認済みの GOLDEN を残して ALTREF を更新する
+	f := encodeRecovery(r, 3, false)
+	if f&vpxext.EflagForceArf == 0 || f&vpxext.EflagNoUpdGf == 0 || f&vpxext.EflagForceGf != 0 {
+		t.Fatalf("更新フレーム: flags %#x", f)
+	}
+
+	// ALTREF が未確認の間の損失は GOLDEN (pts 0) から復帰する
+	r.ReportLoss()
+	f = encodeRecovery(r, 4, false)
+	if f&vpx.EflagForceKf != 0 || f&vpxext.EflagNoRefArf == 0 || f&vpxext.EflagNoRefLast == 0 || f&vpxext.EflagNoRefGf != 0 {
+		t.Fatalf("未確認中の損失: flags %#x", f)
+	}
+
+	// ALTREF が確認されたら次の更新は GOLDEN に入り、損失は ALTREF から復帰する
+	r.Ack(3)
+	for pts := int64(5); pts < 7; pts++ {
+		encodeRecovery(r, pts, false)
+	}
+	f = encodeRecovery(r, 7, false)
+	if f&vpxext.EflagForceGf == 0 || f&vpxext.EflagNoUpdArf == 0 {
+		t.Fatalf("2 回目の更新: flags %#x", f)
+	}
+	r.ReportLoss()
+	f = encodeRecovery(r, 8, false)
+	if f&vpxext.EflagNoRefGf == 0 || f&vpxext.EflagNoRefArf != 0 {
+		t.Fatalf("ALTREF からの復帰: flags %#x", f)
+	}
+}
+
+func TestLossRecoveryKeyframeResetsReferences(t *testing.T) {
+	r := NewLossRecovery(2)
+	encodeRecovery(r, 0, true)
+	r.Ack(0)
+	encodeRecovery(r, 1, false)
+	encodeRecovery(r, 2, false) // ALTREF を更新
+	r.Ack(2)
+
+	// 新しいキーフレームの後は確認されるまで復帰に使える参照がない
+	encodeRecovery(r, 3, true)
+	r.ReportLoss()
+	if f := encodeRecovery(r, 4, false); f&vpx.EflagForceKf == 0 {
+		t.Fatalf("キーフレーム後の損失: flags %#x", f)
+	}
+}
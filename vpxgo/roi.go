@@ -0,0 +1,157 @@
+package vpxgo
+
+import (
+	"fmt"
+	"image"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// macroblockSize は ROI マップとアクティブマップの単位 (16x16 画素) です。
+const macroblockSize = 16
+
+// ROIMap assigns every 16x16 macroblock to one of four segments, each with
+// its own quantizer and loop filter delta. Negative DeltaQ spends more bits on
+// the segment. Segment 0 is the default for unmarked macroblocks.
+type ROIMap struct {
+	Rows     int
+	Cols     int
+	Segments []byte
+
+	DeltaQ          [4]int // -63..63
+	DeltaLF         [4]int // -63..63
+	StaticThreshold [4]int // この値未満の変化しかないマクロブロックは符号化を省略
+}
+
+// NewROIMap returns a map covering a width x height frame with every
+// macroblock in segment 0.
+func NewROIMap(width, height int) *ROIMap {
+	rows := (height + macroblockSize - 1) / macroblockSize
+	cols := (width + macroblockSize - 1) / macroblockSize
+	return &ROIMap{Rows: rows, Cols: cols, Segments: make([]byte, rows*cols)}
+}
+
+// SetRect puts every macroblock overlapping the pixel rectangle r into segment.
+func (m *ROIMap) SetRect(r image.Rectangle, segment int) {
+	forEachMacroblock(r, m.Rows, m.Cols, func(i int) { m.Segments[i] = byte(segment) })
+}
+
+func (m *ROIMap) validate() error {
+	if len(m.Segments) != m.Rows*m.Cols {
+		return fmt.Errorf("ROI マップのサイズが不正です (%d != %dx%d)", len(m.Segments), m.Rows, m.Cols)
+	}
+	for _, s := range m.Segments {
+		if s > 3 {
+			return fmt.Errorf("ROI マップのセグメント番号は 0..3 です: %d", s)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if m.DeltaQ[i] < -63 || m.DeltaQ[i] > 63 || m.DeltaLF[i] < -63 || m.DeltaLF[i] > 63 {
+			return fmt.Errorf("ROI セグメント %d の補正値は -63..63 で指定してください", i)
+		}
+		if m.StaticThreshold[i] < 0 {
+			return fmt.Errorf("ROI セグメント %d の StaticThreshold が負です", i)
+		}
+	}
+	return nil
+}
+
+// ActiveMap marks which 16x16 macroblocks are encoded. Inactive macroblocks
+// are copied from the previous frame and cost almost no bits.
+type ActiveMap struct {
+	Rows   int
+	Cols   int
+	Active []byte // 1: 符号化する, 0: 前フレームをそのまま使う
+}
+
+// NewActiveMap returns a map covering a width x height frame with every
+// macroblock active.
+func NewActiveMap(width, height int) *ActiveMap {
+	rows := (height + macroblockSize - 1) / macroblockSize
+	cols := (width + macroblockSize - 1) / macroblockSize
+	m := &ActiveMap{Rows: rows, Cols: cols, Active: make([]byte, rows*cols)}
+	for i := range m.Active {
+		m.Active[i] = 1
+	}
+	return m
+}
+
+// SetRect marks every macroblock overlapping the pixel rectangle r.
+func (m *ActiveMap) SetRect(r image.Rectangle, active bool) {
+	var v byte
+	if active {
+		v = 1
+	}
+	forEachMacroblock(r, m.Rows, m.Cols, func(i int) { m.Active[i] = v })
+}
+
+// forEachMacroblock は画素の矩形 r に重なるマクロブロックの添字ごとに fn を呼びます。
+func forEachMacroblock(r image.Rectangle, rows, cols int, fn func(i int)) {
+	r = r.Canon()
+	if r.Empty() {
+		return
+	}
+	x0, y0 := max(r.Min.X/macroblockSize, 0), max(r.Min.Y/macroblockSize, 0)
+	x1 := min((r.Max.X+macroblockSize-1)/macroblockSize, cols)
+	y1 := min((r.Max.Y+macroblockSize-1)/macroblockSize, rows)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			fn(y*cols + x)
+		}
+	}
+}
+
+// SetROIMap applies m from the next frame on. Pass nil to disable ROI
+// encoding. ROI maps are only supported by VP8 in the libvpx version this
+// package targets.
+func (e *Encoder) SetROIMap(m *ROIMap) error {
+	if e.codec != CodecVP8 {
+		return fmt.Errorf("ROI マップは VP8 のみ対応しています")
+	}
+	var rm vpxext.ROIMap
+	if m != nil {
+		if err := m.validate(); err != nil {
+			return err
+		}
+		if m.Rows != (e.height+macroblockSize-1)/macroblockSize || m.Cols != (e.width+macroblockSize-1)/macroblockSize {
+			return fmt.Errorf("ROI マップ (%dx%d) がフレームサイズ %dx%d と一致しません", m.Cols, m.Rows, e.width, e.height)
+		}
+		rm = vpxext.ROIMap{Map: m.Segments, Rows: m.Rows, Cols: m.Cols, DeltaQ: m.DeltaQ, DeltaLF: m.DeltaLF}
+		for i, t := range m.StaticThreshold {
+			rm.StaticThreshold[i] = uint(t)
+		}
+	} else {
+		rm.Rows = (e.height + macroblockSize - 1) / macroblockSize
+		rm.Cols = (e.width + macroblockSize - 1) / macroblockSize
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if res := vpxext.SetROIMap(e.ctx, rm); res != vpx.CodecOk {
+		return newCodecError(e.ctx, "ROI マップ設定", res)
+	}
+	return nil
+}
+
+// SetActiveMap applies m from the next frame on. Pass nil to encode every
+// macroblock again.
+func (e *Encoder) SetActiveMap(m *ActiveMap) error {
+	rows := (e.height + macroblockSize - 1) / macroblockSize
+	cols := (e.width + macroblockSize - 1) / macroblockSize
+	var active []byte
+	if m != nil {
+		if m.Rows != rows || m.Cols != cols || len(m.Active) != rows*cols {
+			return fmt.Errorf("アクティブマップ (%dx%d) がフレームサイズ %dx%d と一致しません", m.Cols, m.Rows, e.width, e.height)
+		}
+		active = m.Active
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if res := vpxext.SetActiveMap(e.ctx, active, rows, cols); res != vpx.CodecOk {
+		return newCodecError(e.ctx, "アクティブマップ設定", res)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package vpxgo
+
+import (
+	"fmt"
+)
+
+// RoundtripResult is the outcome of VerifyRoundtrip.
+type RoundtripResult struct {
+	Frames     int   `json:"frames"`
+	Decoded    int   `json:"decoded"`
+	Bytes      int64 `json:"bytes"`
+	Mismatches []int `json:"mismatches,omitempty"` // 復号結果が一致しなかったフレーム番号
+}
+
+// BitExact reports whether every source frame was decoded unchanged.
+func (r *RoundtripResult) BitExact() bool {
+	return r.Frames == r.Decoded && len(r.Mismatches) == 0
+}
+
+// VerifyRoundtrip encodes frames with a lossless VP9 encoder matching their
+// bit depth and chroma format, decodes the result and compares every decoded
+// frame with its source sample by sample.
+func VerifyRoundtrip(frames []*Frame16, fps int) (*RoundtripResult, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("検証するフレームがありません")
+	}
+	first := frames[0]
+	cfg := DefaultEncoderConfig(first.Width, first.Height)
+	cfg.Codec = CodecVP9
+	cfg.FPS = fps
+	cfg.Lossless = true
+	cfg.BitDepth = first.BitDepth
+	cfg.Chroma444 = first.Chroma444
+
+	enc, err := NewEncoder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	dec, err := NewDecoder(CodecVP9)
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+
+	res := &RoundtripResult{Frames: len(frames)}
+	// 復号フレームは表示順に出てくるので、ソースと順番に突き合わせる
+	check := func(packets []Packet) error {
+		for _, p := range packets {
+			res.Bytes += int64(len(p.Data))
+			decoded, err := dec.Decode16(p.Data)
+			if err != nil {
+				return err
+			}
+			for _, f := range decoded {
+				if res.Decoded >= len(frames) {
+					return fmt.Errorf("ソースより多いフレームが復号されました")
+				}
+				if !f.Equal(frames[res.Decoded]) {
+					res.Mismatches = append(res.Mismatches, res.Decoded)
+				}
+				res.Decoded++
+			}
+		}
+		return nil
+	}
+
+	for i, f := range frames {
+		packets, err := enc.EncodeFrame16(f, 0)
+		if err != nil {
+			return res, fmt.Errorf("フレーム %d のエンコードエラー: %w", i, err)
+		}
+		if err := check(packets); err != nil {
+			return res, err
+		}
+	}
+	packets, err := enc.Flush()
+	if err != nil {
+		return res, err
+	}
+	if err := check(packets); err != nil {
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package vpxgo
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// testFrames16 は動きとノイズを含む検証用フレームを作ります (乱数は固定シード)。
+func testFrames16(w, h, depth int, chroma444 bool, n int) []*Frame16 {
+	rng := rand.New(rand.NewSource(1))
+	maxVal := 1<<depth - 1
+	frames := make([]*Frame16, n)
+	for i := range frames {
+		f := NewFrame16(w, h, depth, chroma444)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := (x+y+i*3)*maxVal/(w+h) + rng.Intn(8)
+				f.Y[y*w+x] = uint16(min(v, maxVal))
+			}
+		}
+		for j := range f.U {
+			f.U[j] = uint16(rng.Intn(maxVal + 1))
+			f.V[j] = uint16(maxVal - int(f.U[j]))
+		}
+		frames[i] = f
+	}
+	return frames
+}
+
+func TestLosslessRoundtrip16(t *testing.T) {
+	for _, depth := range []int{8, 10, 12} {
+		for _, chroma444 := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%dbit_444=%v", depth, chroma444), func(t *testing.T) {
+				frames := testFrames16(64, 48, depth, chroma444, 5)
+				res, err := VerifyRoundtrip(frames, 30)
+				if err != nil {
+					if depth > 8 {
+						t.Skipf("libvpx が高ビット深度に対応していない可能性があります: %v", err)
+					}
+					t.Fatal(err)
+				}
+				if res.Decoded != res.Frames {
+					t.Fatalf("復号フレーム数 %d, want %d", res.Decoded, res.Frames)
+				}
+				if !res.BitExact() {
+					t.Fatalf("不一致フレーム: %v", res.Mismatches)
+				}
+			})
+		}
+	}
+}
+
+func TestLosslessRoundtripI420(t *testing.T) {
+	const w, h = 66, 50 // マクロブロックの倍数でないサイズも確認する
+	cfg := DefaultEncoderConfig(w, h)
+	cfg.Codec = CodecVP9
+	cfg.Lossless = true
+	enc, err := NewEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	dec, err := NewDecoder(CodecVP9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	var src, got []*I420Frame
+	for i, f16 := range testFrames16(w, h, 8, false, 5) {
+		f := NewI420Frame(w, h)
+		for j, plane := range [][]uint16{f16.Y, f16.U, f16.V} {
+			dst := [][]byte{f.Y, f.U, f.V}[j]
+			for k, v := range plane {
+				dst[k] = byte(v)
+			}
+		}
+		src = append(src, f)
+		pkts, err := enc.EncodeI420(f, 0)
+		if err != nil {
+			t.Fatalf("フレーム %d: %v", i, err)
+		}
+		for _, p := range pkts {
+			decoded, err := dec.Decode(p.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, decoded...)
+		}
+	}
+	pkts, err := enc.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pkts {
+		decoded, err := dec.Decode(p.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, decoded...)
+	}
+
+	if len(got) != len(src) {
+		t.Fatalf("復号フレーム数 %d, want %d", len(got), len(src))
+	}
+	for i := range src {
+		for j, name := range []string{"Y", "U", "V"} {
+			a := [][]byte{src[i].Y, src[i].U, src[i].V}[j]
+			b := [][]byte{got[i].Y, got[i].U, got[i].V}[j]
+			if !bytes.Equal(a, b) {
+				t.Errorf("フレーム %d の %s プレーンが一致しません", i, name)
+			}
+		}
+	}
+}
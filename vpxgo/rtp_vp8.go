@@ -0,0 +1,66 @@
+package vpxgo
+
+// VP8Payloader splits encoded VP8 frames into RTP payloads carrying the VP8
+// payload descriptor of RFC 7741. RTP headers are left to the transport.
+type VP8Payloader struct {
+	// TemporalLayers が true のとき TL0PICIDX と TID/Y を記述子に含める
+	TemporalLayers bool
+
+	pictureID uint16
+}
+
+// NewVP8Payloader returns a payloader starting at the given 15-bit picture ID.
+func NewVP8Payloader(initialPictureID uint16, temporalLayers bool) *VP8Payloader {
+	return &VP8Payloader{TemporalLayers: temporalLayers, pictureID: initialPictureID & 0x7fff}
+}
+
+// Payload returns the RTP payloads for one encoded frame, each at most mtu bytes.
+// The picture ID only advances when at least one payload is returned.
+func (v *VP8Payloader) Payload(mtu int, pkt Packet) [][]byte {
+	desc := v.descriptor(pkt)
+	maxData := mtu - len(desc)
+	if maxData <= 0 || len(pkt.Data) == 0 {
+		return nil // 何も送らないので PictureID は進めない (受信側が損失と誤認する)
+	}
+	v.pictureID = (v.pictureID + 1) & 0x7fff
+
+	var payloads [][]byte
+	for off := 0; off < len(pkt.Data); off += maxData {
+		end := min(off+maxData, len(pkt.Data))
+		b := make([]byte, len(desc)+end-off)
+		copy(b, desc)
+		if off == 0 {
+			b[0] |= 0x10 // S: パーティションの先頭
+		}
+		copy(b[len(desc):], pkt.Data[off:end])
+		payloads = append(payloads, b)
+	}
+	return payloads
+}
+
+// descriptor builds the payload descriptor (without the S bit) for pkt.
+//
+//	 0 1 2 3 4 5 6 7
+//	|X|R|N|S|R| PID |
+//	|I|L|T|K| RSV   |
+//	|M| PictureID   |
+//	|  PictureID    |
+//	|   TL0PICIDX   |
+//	|TID|Y| KEYIDX  |
+func (v *VP8Payloader) descriptor(pkt Packet) []byte {
+	b := []byte{0x80, 0x80} // X=1, I=1
+	if pkt.Droppable {
+		b[0] |= 0x20 // N: 参照されないフレーム
+	}
+	b = append(b, 0x80|byte(v.pictureID>>8), byte(v.pictureID))
+
+	if v.TemporalLayers {
+		b[1] |= 0x40 | 0x20 // L, T
+		tk := byte(pkt.TemporalLayerID&0x03) << 6
+		if pkt.LayerSync {
+			tk |= 0x20
+		}
+		b = append(b, pkt.TL0PicIdx, tk)
+	}
+	return b
+}
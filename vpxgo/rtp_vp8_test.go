@@ -0,0 +1,72 @@
+package vpxgo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVP8PayloaderFragments(t *testing.T) {
+	v := NewVP8Payloader(0x1234, false)
+	data := make([]byte, 25)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	payloads := v.Payload(14, Packet{Data: data, Droppable: true})
+	// 記述子 4 バイト + データ 10 バイトずつ
+	if len(payloads) != 3 {
+		t.Fatalf("%d ペイロード, want 3", len(payloads))
+	}
+	var joined []byte
+	for i, p := range payloads {
+		wantX := byte(0x80 | 0x20) // X, N
+		if i == 0 {
+			wantX |= 0x10 // S は先頭のみ
+		}
+		if want := []byte{wantX, 0x80, 0x80 | 0x12, 0x34}; !bytes.Equal(p[:4], want) {
+			t.Errorf("ペイロード %d の記述子 % x, want % x", i, p[:4], want)
+		}
+		if len(p) > 14 {
+			t.Errorf("ペイロード %d が MTU を超えています: %d バイト", i, len(p))
+		}
+		joined = append(joined, p[4:]...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("分割したデータを連結しても元に戻りません")
+	}
+}
+
+func TestVP8PayloaderTemporalLayers(t *testing.T) {
+	v := NewVP8Payloader(0x7fff, true)
+	p := v.Payload(1200, Packet{Data: []byte{0}, TemporalLayerID: 2, TL0PicIdx: 9, LayerSync: true})
+	// L, T が立ち、TL0PICIDX と TID=2/Y=1 が続く
+	if want := []byte{0x90, 0xe0, 0xff, 0xff, 9, 0xa0, 0}; !bytes.Equal(p[0], want) {
+		t.Errorf("% x, want % x", p[0], want)
+	}
+	// PictureID は 15 ビットで折り返す
+	p = v.Payload(1200, Packet{Data: []byte{0}})
+	if p[0][2] != 0x80 || p[0][3] != 0x00 {
+		t.Errorf("折り返し後の PictureID % x", p[0][2:4])
+	}
+}
+
+func TestVP8PayloaderSmallMTU(t *testing.T) {
+	v := NewVP8Payloader(0, true)
+	if p := v.Payload(6, Packet{Data: []byte{1, 2, 3}}); p != nil {
+		t.Errorf("記述子だけで MTU を超える場合は nil のはず: %v", p)
+	}
+}
+
+func TestVP8PayloaderEmptyKeepsPictureID(t *testing.T) {
+	v := NewVP8Payloader(5, false)
+	if p := v.Payload(1200, Packet{}); p != nil {
+		t.Fatalf("空のパケットは nil のはず: %v", p)
+	}
+	if p := v.Payload(3, Packet{Data: []byte{1}}); p != nil {
+		t.Fatalf("MTU 不足は nil のはず: %v", p)
+	}
+	// 何も送っていないので PictureID は 5 のまま
+	p := v.Payload(1200, Packet{Data: []byte{1}})
+	if p[0][2] != 0x80 || p[0][3] != 5 {
+		t.Errorf("PictureID % x, want 80 05", p[0][2:4])
+	}
+}
@@ -0,0 +1,77 @@
+package vpxgo
+
+import (
+	"testing"
+	"time"
+)
+
+// flatFrame は輝度が一定のフレームを作ります。
+func flatFrame(w, h int, luma byte) *I420Frame {
+	f := NewI420Frame(w, h)
+	for i := range f.Y {
+		f.Y[i] = luma
+	}
+	return f
+}
+
+// stripeFrame は幅 8 の縦縞を shift 画素ずらしたフレームを作ります。
+func stripeFrame(w, h, shift int) *I420Frame {
+	f := NewI420Frame(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+shift)/8%2 == 0 {
+				f.Y[y*w+x] = 255
+			}
+		}
+	}
+	return f
+}
+
+func TestSceneDetectorHardCut(t *testing.T) {
+	d := NewSceneDetector(SceneDetectorConfig{})
+	for i := 0; i < 20; i++ {
+		luma := byte(50)
+		if i >= 10 {
+			luma = 200
+		}
+		if got := d.Check(flatFrame(128, 72, luma)); got != (i == 10) {
+			t.Errorf("フレーム %d: Check = %v", i, got)
+		}
+	}
+	cuts := d.Cuts()
+	if len(cuts) != 1 || cuts[0].Frame != 10 || cuts[0].Time != time.Second/3 {
+		t.Fatalf("Cuts = %+v", cuts)
+	}
+}
+
+func TestSceneDetectorIgnoresPan(t *testing.T) {
+	d := NewSceneDetector(SceneDetectorConfig{MinSpacing: 1})
+	for i := 0; i < 30; i++ {
+		// 毎フレーム 2 画素ずつ動くので、差分 (SAD) は大きいがほぼ一定
+		d.Check(stripeFrame(128, 72, i*2))
+	}
+	// 動き始めの 1 フレームを除き、同程度の差分が続く間はカットにならない
+	for _, c := range d.Cuts() {
+		if c.Frame > 1 {
+			t.Errorf("パンの途中でカットを検出しました: %+v", c)
+		}
+	}
+}
+
+func TestSceneDetectorMinSpacing(t *testing.T) {
+	d := NewSceneDetector(SceneDetectorConfig{FPS: 30})
+	var cuts []int
+	for i := 0; i < 30; i++ {
+		luma := byte(0)
+		if i/5%2 == 1 {
+			luma = 255
+		}
+		if d.Check(flatFrame(64, 36, luma)) {
+			cuts = append(cuts, i)
+		}
+	}
+	// 既定の最小間隔は 1 秒 (30 フレーム) なので最初のカットだけ
+	if len(cuts) != 1 || cuts[0] != 5 {
+		t.Fatalf("カット %v, want [5]", cuts)
+	}
+}
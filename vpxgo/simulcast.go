@@ -0,0 +1,145 @@
+package vpxgo
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+// SimulcastLayer configures one independently encoded stream of a
+// SimulcastGroup.
+type SimulcastLayer struct {
+	RID         string // RTP の rid (例: "q", "h", "f")
+	Width       int
+	Height      int
+	BitrateKbps int
+}
+
+// SimulcastPacket is an encoded packet tagged with the stream it belongs to.
+type SimulcastPacket struct {
+	RID   string
+	Layer int
+	Packet
+}
+
+// SimulcastGroup encodes one captured frame into several resolutions, each with
+// its own encoder context, in parallel.
+type SimulcastGroup struct {
+	// layers は構築後に変更しない (エンコード中のゴルーチンがロックなしで読む)。
+	// 現在のビットレートは各エンコーダーが持つ
+	layers   []SimulcastLayer
+	encoders []*Encoder
+}
+
+// NewSimulcastGroup creates one encoder per layer. Codec, frame rate and the
+// other settings are taken from base; size and bitrate come from each layer.
+func NewSimulcastGroup(base EncoderConfig, layers []SimulcastLayer) (*SimulcastGroup, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("simulcast レイヤーが指定されていません")
+	}
+	g := &SimulcastGroup{layers: append([]SimulcastLayer(nil), layers...)}
+	seen := map[string]bool{}
+	for _, l := range layers {
+		if seen[l.RID] {
+			g.Close()
+			return nil, fmt.Errorf("rid %q が重複しています", l.RID)
+		}
+		seen[l.RID] = true
+
+		c := base
+		c.Width, c.Height, c.BitrateKbps = l.Width, l.Height, l.BitrateKbps
+		enc, err := NewEncoder(c)
+		if err != nil {
+			g.Close()
+			return nil, fmt.Errorf("simulcast レイヤー %q の初期化失敗: %w", l.RID, err)
+		}
+		g.encoders = append(g.encoders, enc)
+	}
+	return g, nil
+}
+
+// Encode downsamples mat to every layer resolution and encodes the layers
+// concurrently. Packets are returned in layer order.
+func (g *SimulcastGroup) Encode(mat gocv.Mat) ([]SimulcastPacket, error) {
+	results := make([][]Packet, len(g.layers))
+	errs := make([]error, len(g.layers))
+
+	var wg sync.WaitGroup
+	for i := range g.layers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = g.encodeLayer(i, mat)
+		}(i)
+	}
+	wg.Wait()
+
+	var out []SimulcastPacket
+	for i, pkts := range results {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("simulcast レイヤー %q: %w", g.layers[i].RID, errs[i])
+		}
+		for _, p := range pkts {
+			out = append(out, SimulcastPacket{RID: g.layers[i].RID, Layer: i, Packet: p})
+		}
+	}
+	return out, nil
+}
+
+func (g *SimulcastGroup) encodeLayer(i int, src gocv.Mat) ([]Packet, error) {
+	l := g.layers[i]
+	if src.Cols() == l.Width && src.Rows() == l.Height {
+		return g.encoders[i].EncodeFrame(src, 0)
+	}
+
+	scaled := gocv.NewMat()
+	defer scaled.Close()
+	if err := gocv.Resize(src, &scaled, image.Pt(l.Width, l.Height), 0, 0, gocv.InterpolationArea); err != nil {
+		return nil, fmt.Errorf("リサイズエラー: %v", err)
+	}
+	return g.encoders[i].EncodeFrame(scaled, 0)
+}
+
+// SetBitrate changes the target bitrate of the layer with the given rid.
+func (g *SimulcastGroup) SetBitrate(rid string, kbps int) error {
+	i, err := g.index(rid)
+	if err != nil {
+		return err
+	}
+	return g.encoders[i].SetBitrate(kbps)
+}
+
+// RequestKeyframe forces a keyframe on the layer with the given rid, or on all
+// layers when rid is empty.
+func (g *SimulcastGroup) RequestKeyframe(rid string) error {
+	if rid == "" {
+		for _, enc := range g.encoders {
+			enc.RequestKeyframe()
+		}
+		return nil
+	}
+	i, err := g.index(rid)
+	if err != nil {
+		return err
+	}
+	g.encoders[i].RequestKeyframe()
+	return nil
+}
+
+func (g *SimulcastGroup) index(rid string) (int, error) {
+	for i, l := range g.layers {
+		if l.RID == rid {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("不明な rid: %q", rid)
+}
+
+// Close releases the encoders of every layer.
+func (g *SimulcastGroup) Close() {
+	for _, enc := range g.encoders {
+		enc.Close()
+	}
+}
@@ -0,0 +1,175 @@
+package vpxgo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FrameStats is the telemetry recorded for one encoded frame.
+type FrameStats struct {
+	Frame      int64         `json:"frame"`
+	EncodeTime time.Duration `json:"encode_time_ns"`
+	Size       int           `json:"size"`
+	Keyframe   bool          `json:"keyframe"`
+	Quantizer  int           `json:"quantizer"`
+	// Dropped はこのフレームまでにレート制御が出力せずに間引いた入力フレーム数。
+	// 先読み (lag) 中で出力がまだないだけのフレームは含まない
+	Dropped int `json:"dropped"`
+
+	// PSNR は EnablePSNR 時のみ、SSIM は EnableSSIM 時のみ設定される
+	PSNR  float64 `json:"psnr,omitempty"`
+	PSNRY float64 `json:"psnr_y,omitempty"`
+	PSNRU float64 `json:"psnr_u,omitempty"`
+	PSNRV float64 `json:"psnr_v,omitempty"`
+	SSIM  float64 `json:"ssim,omitempty"`
+}
+
+// EncoderStats aggregates FrameStats over the lifetime of an encoder.
+type EncoderStats struct {
+	Frames          int64         `json:"frames"`
+	Keyframes       int64         `json:"keyframes"`
+	Dropped         int64         `json:"dropped"`
+	Bytes           int64         `json:"bytes"`
+	TotalEncodeTime time.Duration `json:"total_encode_time_ns"`
+	MaxEncodeTime   time.Duration `json:"max_encode_time_ns"`
+	AvgPSNR         float64       `json:"avg_psnr,omitempty"`
+	AvgSSIM         float64       `json:"avg_ssim,omitempty"`
+	Last            FrameStats    `json:"last"`
+
+	psnrSum, ssimSum float64
+	psnrN, ssimN     int64
+}
+
+// AvgEncodeTime returns the mean encode latency per frame.
+func (s EncoderStats) AvgEncodeTime() time.Duration {
+	if s.Frames == 0 {
+		return 0
+	}
+	return s.TotalEncodeTime / time.Duration(s.Frames)
+}
+
+// statsCollector accumulates per-frame statistics; it is read concurrently by
+// monitoring code while the encoder runs.
+type statsCollector struct {
+	mu      sync.Mutex
+	totals  EncoderStats
+	logger  *StatsLogger
+	metrics *Metrics
+}
+
+func (c *statsCollector) add(f FrameStats) {
+	c.mu.Lock()
+	t := &c.totals
+	t.Frames++
+	if f.Keyframe {
+		t.Keyframes++
+	}
+	t.Dropped += int64(f.Dropped)
+	t.Bytes += int64(f.Size)
+	t.TotalEncodeTime += f.EncodeTime
+	t.MaxEncodeTime = max(t.MaxEncodeTime, f.EncodeTime)
+	if f.PSNR > 0 {
+		t.psnrSum += f.PSNR
+		t.psnrN++
+		t.AvgPSNR = t.psnrSum / float64(t.psnrN)
+	}
+	if f.SSIM > 0 {
+		t.ssimSum += f.SSIM
+		t.ssimN++
+		t.AvgSSIM = t.ssimSum / float64(t.ssimN)
+	}
+	t.Last = f
+	logger, metrics := c.logger, c.metrics
+	c.mu.Unlock()
+
+	if metrics != nil {
+		metrics.FrameEncoded(f)
+	}
+	if logger != nil {
+		if err := logger.Log(f); err != nil {
+			log.Printf("統計ログ書き込みエラー: %v", err)
+		}
+	}
+}
+
+func (c *statsCollector) snapshot() EncoderStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.totals
+}
+
+func (c *statsCollector) setLogger(l *StatsLogger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = l
+}
+
+func (c *statsCollector) setMetrics(m *Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = m
+}
+
+// StatsFormat selects the output format of a StatsLogger.
+type StatsFormat int
+
+const (
+	StatsCSV  StatsFormat = iota
+	StatsJSON             // 1 行 1 フレームの JSON Lines
+)
+
+var statsCSVHeader = []string{"frame", "encode_time_us", "size", "keyframe", "quantizer", "psnr", "psnr_y", "psnr_u", "psnr_v", "ssim", "dropped"}
+
+// StatsLogger writes one record per encoded frame as CSV or JSON Lines.
+type StatsLogger struct {
+	mu     sync.Mutex
+	format StatsFormat
+	enc    *json.Encoder
+	csv    *csv.Writer
+	header bool
+}
+
+// NewStatsLogger writes per-frame statistics to w in the given format.
+func NewStatsLogger(w io.Writer, format StatsFormat) *StatsLogger {
+	l := &StatsLogger{format: format}
+	if format == StatsJSON {
+		l.enc = json.NewEncoder(w)
+	} else {
+		l.csv = csv.NewWriter(w)
+	}
+	return l
+}
+
+// Log writes one frame record.
+func (l *StatsLogger) Log(f FrameStats) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format == StatsJSON {
+		return l.enc.Encode(f)
+	}
+	if !l.header {
+		if err := l.csv.Write(statsCSVHeader); err != nil {
+			return err
+		}
+		l.header = true
+	}
+	ff := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	l.csv.Write([]string{
+		strconv.FormatInt(f.Frame, 10),
+		strconv.FormatInt(f.EncodeTime.Microseconds(), 10),
+		strconv.Itoa(f.Size),
+		strconv.FormatBool(f.Keyframe),
+		strconv.Itoa(f.Quantizer),
+		ff(f.PSNR), ff(f.PSNRY), ff(f.PSNRU), ff(f.PSNRV), ff(f.SSIM),
+		strconv.Itoa(f.Dropped),
+	})
+	// 長時間実行中でも途中経過を読めるよう毎フレーム書き出す
+	l.csv.Flush()
+	return l.csv.Error()
+}
@@ -0,0 +1,55 @@
+package vpxgo
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// encoderStatus is the JSON document served on /status.
+type encoderStatus struct {
+	Config  EncoderConfig `json:"config"`
+	Stats   EncoderStats  `json:"stats"`
+	Uptime  string        `json:"uptime"`
+	Started time.Time     `json:"started"`
+}
+
+// NewStatusServer returns an HTTP server exposing Prometheus metrics on
+// /metrics and the encoder configuration and statistics as JSON on /status.
+// The RTP transport POSTs the (compound) RTCP packets it receives to /rtcp to
+// update the packet loss metrics. The caller starts it with ListenAndServe.
+func NewStatusServer(addr string, m *Metrics, enc *Encoder) *http.Server {
+	started := time.Now()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		st := encoderStatus{
+			Config:  enc.Config(),
+			Stats:   enc.Stats(),
+			Uptime:  time.Since(started).Round(time.Second).String(),
+			Started: started,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		je := json.NewEncoder(w)
+		je.SetIndent("", "  ")
+		je.Encode(st)
+	})
+	mux.HandleFunc("/rtcp", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST を使用してください", http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := m.ObserveRTCP(b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,223 @@
+package vpxgo
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// SVCConfig describes VP9 spatial and temporal scalability. One encode then
+// yields a superframe holding every spatial layer, which EncodeFrame splits
+// into per-layer packets.
+type SVCConfig struct {
+	SpatialLayers  int `json:"spatial_layers"`  // 1..3
+	TemporalLayers int `json:"temporal_layers"` // 1..3
+
+	// ScalingNum/ScalingDen は各空間レイヤーの縮小率 (下位レイヤーから順, 最上位は 1/1)
+	ScalingNum []int `json:"scaling_num"`
+	ScalingDen []int `json:"scaling_den"`
+
+	// LayerBitratesKbps[s*TemporalLayers+t] は空間レイヤー s の時間レイヤー t までの
+	// 累積ビットレート (kbps)。空なら面積比で BitrateKbps を配分する
+	LayerBitratesKbps []int `json:"layer_bitrates_kbps"`
+}
+
+// temporalShare は時間レイヤー数ごとの累積ビットレート配分 (%) です。
+var temporalShare = map[int][]int{1: {100}, 2: {60, 100}, 3: {40, 60, 100}}
+
+// withDefaults fills unset fields and validates the configuration against the
+// total target bitrate.
+func (s SVCConfig) withDefaults(totalKbps int) (SVCConfig, error) {
+	if s.SpatialLayers < 1 || s.SpatialLayers > 3 {
+		return s, fmt.Errorf("空間レイヤー数は 1..3 で指定してください: %d", s.SpatialLayers)
+	}
+	if s.TemporalLayers == 0 {
+		s.TemporalLayers = 1
+	}
+	if s.TemporalLayers < 1 || s.TemporalLayers > 3 {
+		return s, fmt.Errorf("時間レイヤー数は 1..3 で指定してください: %d", s.TemporalLayers)
+	}
+
+	if s.ScalingNum == nil && s.ScalingDen == nil {
+		// 1 段ごとに縦横 1/2
+		for i := 0; i < s.SpatialLayers; i++ {
+			s.ScalingNum = append(s.ScalingNum, 1)
+			s.ScalingDen = append(s.ScalingDen, 1<<(s.SpatialLayers-1-i))
+		}
+	}
+	if len(s.ScalingNum) != s.SpatialLayers || len(s.ScalingDen) != s.SpatialLayers {
+		return s, fmt.Errorf("ScalingNum/ScalingDen は空間レイヤー数 (%d) 分必要です", s.SpatialLayers)
+	}
+	for i := range s.ScalingNum {
+		if s.ScalingNum[i] <= 0 || s.ScalingDen[i] <= 0 || s.ScalingNum[i] > s.ScalingDen[i] {
+			return s, fmt.Errorf("不正な縮小率: %d/%d", s.ScalingNum[i], s.ScalingDen[i])
+		}
+	}
+
+	layers := s.SpatialLayers * s.TemporalLayers
+	if s.LayerBitratesKbps == nil {
+		var total float64
+		areas := make([]float64, s.SpatialLayers)
+		for i := range areas {
+			r := float64(s.ScalingNum[i]) / float64(s.ScalingDen[i])
+			areas[i] = r * r
+			total += areas[i]
+		}
+		for i := range areas {
+			area := areas[i] / total
+			for _, pct := range temporalShare[s.TemporalLayers] {
+				s.LayerBitratesKbps = append(s.LayerBitratesKbps, int(float64(totalKbps)*area)*pct/100)
+			}
+		}
+	}
+	if len(s.LayerBitratesKbps) != layers {
+		return s, fmt.Errorf("LayerBitratesKbps はレイヤー数 (%d) 分必要です", layers)
+	}
+	return s, nil
+}
+
+// apply writes the layer settings into the libvpx encoder configuration.
+func (s SVCConfig) apply(cfg *vpx.CodecEncCfg) {
+	cfg.SsNumberLayers = uint32(s.SpatialLayers)
+	cfg.TsNumberLayers = uint32(s.TemporalLayers)
+	cfg.RcEndUsage = vpx.Cbr // 1 パス SVC は CBR のみ
+	cfg.GLagInFrames = 0
+
+	decimators := map[int][]uint32{1: {1}, 2: {2, 1}, 3: {4, 2, 1}}[s.TemporalLayers]
+	copy(cfg.TsRateDecimator[:], decimators)
+
+	var total uint32
+	for sl := 0; sl < s.SpatialLayers; sl++ {
+		for tl := 0; tl < s.TemporalLayers; tl++ {
+			i := sl*s.TemporalLayers + tl
+			cfg.LayerTargetBitrate[i] = uint32(s.LayerBitratesKbps[i])
+		}
+		top := uint32(s.LayerBitratesKbps[sl*s.TemporalLayers+s.TemporalLayers-1])
+		cfg.SsTargetBitrate[sl] = top
+		total += top
+	}
+	cfg.RcTargetBitrate = total
+	cfg.TemporalLayeringMode = int32(s.temporalLayeringMode())
+}
+
+func (s SVCConfig) temporalLayeringMode() int {
+	switch s.TemporalLayers {
+	case 2:
+		return vpxext.Vp9eTemporalLayeringMode0101
+	case 3:
+		return vpxext.Vp9eTemporalLayeringMode0212
+	}
+	return vpxext.Vp9eTemporalLayeringModeNoLayering
+}
+
+// parameters returns the VP9E_SET_SVC_PARAMETERS values for the configuration.
+func (s SVCConfig) parameters(cfg *vpx.CodecEncCfg) vpxext.SVCParameters {
+	p := vpxext.SVCParameters{TemporalLayeringMode: s.temporalLayeringMode()}
+	for sl := 0; sl < s.SpatialLayers; sl++ {
+		for tl := 0; tl < s.TemporalLayers; tl++ {
+			i := sl*s.TemporalLayers + tl
+			p.MaxQuantizers[i] = int(cfg.RcMaxQuantizer)
+			p.MinQuantizers[i] = int(cfg.RcMinQuantizer)
+		}
+		p.ScalingFactorNum[sl] = s.ScalingNum[sl]
+		p.ScalingFactorDen[sl] = s.ScalingDen[sl]
+	}
+	return p
+}
+
+// superframeEntry is one frame of a VP9 superframe with its position in the
+// index, which is the spatial layer for SVC output.
+type superframeEntry struct {
+	index int
+	data  []byte
+}
+
+// splitVP9Superframe returns the frames contained in a VP9 superframe, or data
+// itself (index -1) when it carries no superframe index. Zero-size entries of
+// skipped layers are omitted but keep their position, so the index of the
+// following frames is unchanged.
+//
+// インデックスはデータ末尾にあり、先頭と末尾のマーカーバイトは
+// 0b110[サイズ長-1:2][フレーム数-1:3] です。
+func splitVP9Superframe(data []byte) []superframeEntry {
+	if len(data) == 0 {
+		return nil
+	}
+	whole := []superframeEntry{{index: -1, data: data}}
+	marker := data[len(data)-1]
+	if marker&0xe0 != 0xc0 {
+		return whole
+	}
+	frames := int(marker&0x07) + 1
+	mag := int((marker>>3)&0x03) + 1
+	indexSz := 2 + mag*frames
+	if len(data) < indexSz || data[len(data)-indexSz] != marker {
+		return whole
+	}
+
+	var out []superframeEntry
+	index := data[len(data)-indexSz+1:]
+	off := 0
+	for i := 0; i < frames; i++ {
+		var sz [4]byte
+		copy(sz[:], index[i*mag:(i+1)*mag])
+		n := int(binary.LittleEndian.Uint32(sz[:]))
+		if n == 0 {
+			continue
+		}
+		if off+n > len(data)-indexSz {
+			return whole
+		}
+		out = append(out, superframeEntry{index: i, data: data[off : off+n]})
+		off += n
+	}
+	return out
+}
+
+// SuperframeUpTo rebuilds the superframe of one picture from its per-layer
+// packets, keeping the spatial layers up to and including maxSpatial, e.g. to
+// forward a lower resolution to a receiver.
+func SuperframeUpTo(pkts []Packet, maxSpatial int) []byte {
+	var frames [][]byte
+	for _, p := range pkts {
+		if p.SpatialLayerID <= maxSpatial {
+			frames = append(frames, p.Data)
+		}
+	}
+	if len(frames) == 0 {
+		return nil
+	}
+	return buildVP9Superframe(frames)
+}
+
+// buildVP9Superframe joins frames into a single superframe with an index.
+func buildVP9Superframe(frames [][]byte) []byte {
+	if len(frames) == 1 {
+		return frames[0]
+	}
+
+	largest := 0
+	for _, f := range frames {
+		largest = max(largest, len(f))
+	}
+	mag := 1
+	for largest >= 1<<(8*mag) {
+		mag++
+	}
+	marker := byte(0xc0) | byte(mag-1)<<3 | byte(len(frames)-1)
+
+	var out []byte
+	for _, f := range frames {
+		out = append(out, f...)
+	}
+	out = append(out, marker)
+	for _, f := range frames {
+		var sz [4]byte
+		binary.LittleEndian.PutUint32(sz[:], uint32(len(f)))
+		out = append(out, sz[:mag]...)
+	}
+	return append(out, marker)
+}
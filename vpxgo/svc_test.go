@@ -0,0 +1,79 @@
+package vpxgo
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVP9SuperframeRoundtrip(t *testing.T) {
+	big := bytes.Repeat([]byte{7}, 300) // サイズ欄が 2 バイトになる
+	tests := []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"single", [][]byte{{1, 2, 3}}},
+		{"two", [][]byte{{1, 2, 3}, {4, 5}}},
+		{"three-mag2", [][]byte{{1}, big, {9, 9}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitVP9Superframe(buildVP9Superframe(tt.frames))
+			if len(got) != len(tt.frames) {
+				t.Fatalf("%d フレーム, want %d", len(got), len(tt.frames))
+			}
+			for i, f := range got {
+				want := i
+				if len(tt.frames) == 1 {
+					want = -1 // 1 フレームならインデックスを付けない
+				}
+				if f.index != want || !bytes.Equal(f.data, tt.frames[i]) {
+					t.Errorf("フレーム %d: index %d data %v, want %d %v", i, f.index, f.data, want, tt.frames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitVP9SuperframeSkippedLayer(t *testing.T) {
+	// 空間レイヤー 1 がスキップされ、インデックスにサイズ 0 で残っているスーパーフレーム
+	marker := byte(0xc0 | 2)
+	data := []byte{1, 1, 1, 3, 3}
+	data = append(data, marker, 3, 0, 2, marker)
+
+	got := splitVP9Superframe(data)
+	if len(got) != 2 {
+		t.Fatalf("%d フレーム, want 2", len(got))
+	}
+	if got[0].index != 0 || got[1].index != 2 {
+		t.Errorf("インデックス %d, %d, want 0, 2", got[0].index, got[1].index)
+	}
+	if !bytes.Equal(got[1].data, []byte{3, 3}) {
+		t.Errorf("レイヤー 2 のデータ %v", got[1].data)
+	}
+}
+
+func TestSplitVP9SuperframeInvalidIndex(t *testing.T) {
+	// 末尾がマーカーに見えても先頭マーカーが一致しなければそのまま返す
+	data := []byte{1, 2, 3, 0xc1}
+	got := splitVP9Superframe(data)
+	if len(got) != 1 || got[0].index != -1 || !bytes.Equal(got[0].data, data) {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestSuperframeUpTo(t *testing.T) {
+	pkts := []Packet{
+		{Data: []byte{1}, SpatialLayerID: 0},
+		{Data: []byte{3, 3}, SpatialLayerID: 2},
+	}
+	if got := SuperframeUpTo(pkts, 1); !bytes.Equal(got, []byte{1}) {
+		t.Errorf("maxSpatial 1: %v", got)
+	}
+	got := splitVP9Superframe(SuperframeUpTo(pkts, 2))
+	if len(got) != 2 || !bytes.Equal(got[1].data, []byte{3, 3}) {
+		t.Errorf("maxSpatial 2: %+v", got)
+	}
+	if got := SuperframeUpTo(pkts[1:], 0); got != nil {
+		t.Errorf("該当レイヤーなし: %v", got)
+	}
+}
@@ -0,0 +1,217 @@
+package vpxgo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// SweepPoint is one encoder setting of a rate-distortion sweep.
+type SweepPoint struct {
+	Width       int `json:"width"`
+	Height      int `json:"height"`
+	BitrateKbps int `json:"bitrate_kbps"`
+	CpuUsed     int `json:"cpu_used"`
+}
+
+// SweepResult is the measured outcome of encoding the clip at one SweepPoint.
+// Quality is measured at the source resolution after upscaling the decoded
+// frames, so results of different resolutions are comparable.
+type SweepResult struct {
+	SweepPoint
+	Frames     int            `json:"frames"`
+	Bytes      int64          `json:"bytes"`
+	ActualKbps float64        `json:"actual_kbps"`
+	EncodeFPS  float64        `json:"encode_fps"`
+	Quality    QualitySummary `json:"quality"`
+	Output     string         `json:"output,omitempty"`
+}
+
+// SweepReport is the JSON report written by the sweep mode.
+type SweepReport struct {
+	Source       string        `json:"source"`
+	SourceWidth  int           `json:"source_width"`
+	SourceHeight int           `json:"source_height"`
+	FPS          float64       `json:"fps"`
+	Codec        Codec         `json:"codec"`
+	Results      []SweepResult `json:"results"`
+}
+
+// SweepOptions controls RunSweep.
+type SweepOptions struct {
+	Codec     Codec
+	Points    []SweepPoint
+	MaxFrames int    // 0 ならクリップ全体
+	OutDir    string // 空でなければ各設定のエンコード結果を IVF で保存する
+}
+
+// RunSweep encodes the clip at srcPath once per point and measures size,
+// encode speed and PSNR/SSIM against the source.
+func RunSweep(srcPath string, opts SweepOptions) (SweepReport, error) {
+	report := SweepReport{Source: srcPath, Codec: opts.Codec}
+	for _, p := range opts.Points {
+		r, err := sweepOne(srcPath, opts, p, &report)
+		if err != nil {
+			return report, fmt.Errorf("%dx%d %dkbps cpu-used %d: %w", p.Width, p.Height, p.BitrateKbps, p.CpuUsed, err)
+		}
+		report.Results = append(report.Results, r)
+	}
+	return report, nil
+}
+
+func sweepOne(srcPath string, opts SweepOptions, p SweepPoint, report *SweepReport) (SweepResult, error) {
+	res := SweepResult{SweepPoint: p}
+
+	src, srcCloser, err := OpenFrameSource(srcPath)
+	if err != nil {
+		return res, err
+	}
+	defer srcCloser.Close()
+
+	fps := src.FPS()
+	if fps <= 0 {
+		fps = 30
+	}
+	report.FPS = fps
+
+	// ソース解像度を知るため先頭フレームを読む
+	first, err := src.ReadFrame()
+	if err != nil {
+		return res, fmt.Errorf("ソースフレーム読み込みエラー: %v", err)
+	}
+	report.SourceWidth, report.SourceHeight = first.Width, first.Height
+	if res.Width == 0 || res.Height == 0 {
+		res.Width, res.Height = first.Width, first.Height
+	}
+
+	cfg := DefaultEncoderConfig(res.Width, res.Height)
+	cfg.Codec = opts.Codec
+	cfg.FPS = int(fps + 0.5)
+	cfg.BitrateKbps = p.BitrateKbps
+	cfg.Controls.CpuUsed = &p.CpuUsed
+	enc, err := NewEncoder(cfg)
+	if err != nil {
+		return res, err
+	}
+	defer enc.Close()
+
+	dec, err := NewDecoder(opts.Codec)
+	if err != nil {
+		return res, err
+	}
+	defer dec.Close()
+
+	var ivf *IVFWriter
+	if opts.OutDir != "" {
+		res.Output = filepath.Join(opts.OutDir, fmt.Sprintf("%s_%dx%d_%dk_cpu%d.ivf",
+			strings.ToLower(opts.Codec.String()), res.Width, res.Height, p.BitrateKbps, p.CpuUsed))
+		f, err := os.Create(res.Output)
+		if err != nil {
+			return res, err
+		}
+		defer f.Close()
+		if ivf, err = NewIVFWriter(f, opts.Codec, res.Width, res.Height, cfg.FPS); err != nil {
+			return res, err
+		}
+		defer ivf.Close()
+	}
+
+	// 先読み (lag) があるため、エンコード済みパケットの PTS でソースフレームを引き当てる
+	pending := map[int64]*I420Frame{}
+	var quality []FrameQuality
+	handle := func(pkts []Packet) error {
+		for _, pkt := range pkts {
+			res.Bytes += int64(len(pkt.Data))
+			if ivf != nil {
+				if err := ivf.WritePacket(pkt); err != nil {
+					return err
+				}
+			}
+			frames, err := dec.Decode(pkt.Data)
+			if err != nil {
+				return err
+			}
+			orig, ok := pending[pkt.PTS]
+			if !ok || len(frames) == 0 {
+				continue
+			}
+			delete(pending, pkt.PTS)
+			for _, f := range frames {
+				up, err := ScaleI420(f, orig.Width, orig.Height)
+				if err != nil {
+					return err
+				}
+				q, err := compareFrames(orig, up)
+				if err != nil {
+					return err
+				}
+				q.Frame = int(pkt.PTS)
+				quality = append(quality, q)
+			}
+		}
+		return nil
+	}
+
+	var encodeTime time.Duration
+	frame := first
+	for n := int64(0); frame != nil; n++ {
+		scaled, err := ScaleI420(frame, res.Width, res.Height)
+		if err != nil {
+			return res, err
+		}
+		pending[n] = frame
+
+		start := time.Now()
+		pkts, err := enc.EncodeI420(scaled, 0)
+		encodeTime += time.Since(start)
+		if err != nil {
+			return res, err
+		}
+		if err := handle(pkts); err != nil {
+			return res, err
+		}
+		res.Frames++
+
+		if opts.MaxFrames > 0 && res.Frames >= opts.MaxFrames {
+			break
+		}
+		if frame, err = src.ReadFrame(); err == io.EOF {
+			frame = nil
+		} else if err != nil {
+			return res, err
+		}
+	}
+
+	start := time.Now()
+	pkts, err := enc.Flush()
+	encodeTime += time.Since(start)
+	if err != nil {
+		return res, err
+	}
+	if err := handle(pkts); err != nil {
+		return res, err
+	}
+
+	duration := float64(res.Frames) / fps
+	res.ActualKbps = float64(res.Bytes) * 8 / duration / 1000
+	res.EncodeFPS = float64(res.Frames) / encodeTime.Seconds()
+	res.Quality = summarizeQuality(quality)
+	res.Quality.Missing = res.Frames - len(quality)
+	return res, nil
+}
+
+// WriteSweepTable prints the results as an aligned text table.
+func WriteSweepTable(w io.Writer, report SweepReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "size\ttarget kbps\tcpu-used\tactual kbps\tencode fps\tPSNR\tPSNR-Y\tSSIM\t")
+	for _, r := range report.Results {
+		fmt.Fprintf(tw, "%dx%d\t%d\t%d\t%.1f\t%.1f\t%.2f\t%.2f\t%.4f\t\n",
+			r.Width, r.Height, r.BitrateKbps, r.CpuUsed, r.ActualKbps, r.EncodeFPS,
+			r.Quality.MeanPSNR, r.Quality.MeanPSNRY, r.Quality.MeanSSIM)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,172 @@
+package vpxgo
+
+import (
+	"fmt"
+
+	"libvpxGo/vpxext"
+
+	"github.com/xlab/libvpx-go/vpx"
+)
+
+// TemporalLayerConfig describes VP8 temporal scalability with 1 to 3 layers.
+// Zero values are filled with the libvpx example patterns for the layer count.
+type TemporalLayerConfig struct {
+	Layers int `json:"layers"`
+
+	// LayerBitratesKbps は各レイヤーまでの累積ビットレート (kbps)
+	LayerBitratesKbps []int `json:"layer_bitrates_kbps"`
+	// RateDecimators は各レイヤーのフレームレート間引き率 (3 レイヤーなら 4,2,1)
+	RateDecimators []int `json:"rate_decimators"`
+	// Periodicity はレイヤーパターンの周期 (フレーム数, 最大 16)
+	Periodicity int `json:"periodicity"`
+	// LayerIDs はパターン内の各フレームのレイヤー ID (長さ Periodicity)
+	LayerIDs []int `json:"layer_ids"`
+}
+
+// withDefaults fills unset fields and validates the configuration against the
+// total target bitrate.
+func (t TemporalLayerConfig) withDefaults(totalKbps int) (TemporalLayerConfig, error) {
+	if t.Layers < 1 || t.Layers > 3 {
+		return t, fmt.Errorf("テンポラルレイヤー数は 1..3 で指定してください: %d", t.Layers)
+	}
+
+	var share []int // 累積ビットレートの割合 (%)
+	switch t.Layers {
+	case 1:
+		share = []int{100}
+		if t.Periodicity == 0 {
+			t.Periodicity, t.LayerIDs, t.RateDecimators = 1, []int{0}, []int{1}
+		}
+	case 2:
+		share = []int{60, 100}
+		if t.Periodicity == 0 {
+			t.Periodicity, t.LayerIDs, t.RateDecimators = 2, []int{0, 1}, []int{2, 1}
+		}
+	case 3:
+		share = []int{40, 60, 100}
+		if t.Periodicity == 0 {
+			t.Periodicity, t.LayerIDs, t.RateDecimators = 4, []int{0, 2, 1, 2}, []int{4, 2, 1}
+		}
+	}
+	if t.LayerBitratesKbps == nil {
+		for _, pct := range share {
+			t.LayerBitratesKbps = append(t.LayerBitratesKbps, totalKbps*pct/100)
+		}
+	}
+
+	if t.Periodicity < 1 || t.Periodicity > vpx.TsMaxPeriodicity {
+		return t, fmt.Errorf("Periodicity は 1..%d で指定してください: %d", vpx.TsMaxPeriodicity, t.Periodicity)
+	}
+	if len(t.LayerIDs) != t.Periodicity {
+		return t, fmt.Errorf("LayerIDs の長さ (%d) が Periodicity (%d) と一致しません", len(t.LayerIDs), t.Periodicity)
+	}
+	for _, id := range t.LayerIDs {
+		if id < 0 || id >= t.Layers {
+			return t, fmt.Errorf("不正なレイヤー ID: %d", id)
+		}
+	}
+	if len(t.LayerBitratesKbps) != t.Layers || len(t.RateDecimators) != t.Layers {
+		return t, fmt.Errorf("LayerBitratesKbps と RateDecimators はレイヤー数 (%d) 分必要です", t.Layers)
+	}
+	for i := 1; i < t.Layers; i++ {
+		if t.LayerBitratesKbps[i] < t.LayerBitratesKbps[i-1] {
+			return t, fmt.Errorf("LayerBitratesKbps は累積値 (昇順) で指定してください: %v", t.LayerBitratesKbps)
+		}
+	}
+	return t, nil
+}
+
+// apply writes the layer settings into the libvpx encoder configuration.
+func (t TemporalLayerConfig) apply(cfg *vpx.CodecEncCfg) {
+	cfg.TsNumberLayers = uint32(t.Layers)
+	for i := 0; i < t.Layers; i++ {
+		cfg.TsTargetBitrate[i] = uint32(t.LayerBitratesKbps[i])
+		cfg.TsRateDecimator[i] = uint32(t.RateDecimators[i])
+	}
+	cfg.TsPeriodicity = uint32(t.Periodicity)
+	for i, id := range t.LayerIDs {
+		cfg.TsLayerID[i] = uint32(id)
+	}
+	cfg.RcTargetBitrate = uint32(t.LayerBitratesKbps[t.Layers-1])
+}
+
+// temporalLayers tracks the layer pattern position and the metadata needed by
+// the RTP payload descriptor (TL0PICIDX, layer sync).
+//
+// レイヤー n のフレームは参照バッファ n (0=LAST, 1=GOLDEN, 2=ALTREF) だけを更新し、
+// n より上位のバッファは参照しないので、上位レイヤーを捨てても復号できます。
+// さらにパターンの各周期で各レイヤーの最初のフレームは LAST (TL0) だけを参照するので、
+// 受信側はその周期ごとに上位レイヤーへ切り替えられます (LayerSync, RFC 7741 の Y ビット)。
+type temporalLayers struct {
+	cfg       TemporalLayerConfig
+	frame     int
+	tl0PicIdx uint8
+	synced    [3]bool // この周期で各レイヤーの同期フレームを出したか
+	sync      bool    // 直前に next で決めたフレームが同期フレームか
+}
+
+func newTemporalLayers(cfg TemporalLayerConfig) *temporalLayers {
+	// 最初の TL0 フレームで 0 になるようにする
+	return &temporalLayers{cfg: cfg, tl0PicIdx: 255}
+}
+
+var (
+	noRefFlags = [3]vpx.EncFrameFlags{vpxext.EflagNoRefLast, vpxext.EflagNoRefGf, vpxext.EflagNoRefArf}
+	noUpdFlags = [3]vpx.EncFrameFlags{vpxext.EflagNoUpdLast, vpxext.EflagNoUpdGf, vpxext.EflagNoUpdArf}
+)
+
+// next returns the layer and reference flags for the frame about to be encoded.
+// A forced keyframe restarts the pattern so that it always lands on layer 0.
+func (t *temporalLayers) next(forceKf bool) (int, vpx.EncFrameFlags) {
+	if forceKf {
+		t.frame = 0
+	}
+	if t.frame%t.cfg.Periodicity == 0 {
+		t.synced = [3]bool{}
+	}
+	layer := t.cfg.LayerIDs[t.frame%t.cfg.Periodicity]
+	t.frame++
+
+	var flags vpx.EncFrameFlags
+	t.sync = false
+	if t.cfg.Layers == 1 {
+		return layer, flags
+	}
+	if layer > 0 && !t.synced[layer] {
+		// TL0 (LAST) だけを参照する同期フレームにする。中間レイヤーの GOLDEN も
+		// 参照すると、そのレイヤーを受信していない側が切り替えた時に復号できない
+		flags |= noRefFlags[1] | noRefFlags[2]
+		t.synced[layer] = true
+		t.sync = true
+	}
+	for buf := 0; buf < 3; buf++ {
+		if buf > layer {
+			flags |= noRefFlags[buf]
+		}
+		if buf != layer {
+			flags |= noUpdFlags[buf]
+		}
+	}
+	return layer, flags
+}
+
+// annotate fills the temporal layer metadata of an encoded packet. A keyframe
+// that libvpx inserted on its own is reported as layer 0 and restarts the
+// pattern, so that dropping upper layers never drops a keyframe.
+func (t *temporalLayers) annotate(p *Packet, layer int) {
+	sync := t.sync
+	if p.Keyframe {
+		// キーフレームはパターンの先頭 (位置 0) とみなし、次のフレームから周期をやり直す
+		layer = 0
+		t.frame = 1
+		t.synced = [3]bool{}
+		sync = false
+	}
+	if layer == 0 {
+		t.tl0PicIdx++
+	}
+
+	p.TemporalLayerID = layer
+	p.TL0PicIdx = t.tl0PicIdx
+	p.LayerSync = sync
+}
@@ -0,0 +1,74 @@
+package vpxgo
+
+import (
+	"testing"
+
+	"libvpxGo/vpxext"
+)
+
+func newTestTemporalLayers(t *testing.T, layers int) *temporalLayers {
+	t.Helper()
+	cfg, err := TemporalLayerConfig{Layers: layers}.withDefaults(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTemporalLayers(cfg)
+}
+
+func TestTemporalLayersPattern(t *testing.T) {
+	tl := newTestTemporalLayers(t, 3)
+	wantLayers := []int{0, 2, 1, 2, 0, 2, 1, 2}
+	wantSync := []bool{false, true, true, false, false, true, true, false}
+	for i := range wantLayers {
+		layer, flags := tl.next(false)
+		p := Packet{Keyframe: i == 0}
+		tl.annotate(&p, layer)
+		if p.TemporalLayerID != wantLayers[i] {
+			t.Errorf("フレーム %d: レイヤー %d, want %d", i, p.TemporalLayerID, wantLayers[i])
+		}
+		if p.LayerSync != wantSync[i] {
+			t.Errorf("フレーム %d: LayerSync %v, want %v", i, p.LayerSync, wantSync[i])
+		}
+		// 上位バッファは参照せず、自レイヤーのバッファだけを更新する
+		for buf := 0; buf < 3; buf++ {
+			if buf > layer && flags&noRefFlags[buf] == 0 {
+				t.Errorf("フレーム %d: バッファ %d を参照しうる", i, buf)
+			}
+			if buf != layer && flags&noUpdFlags[buf] == 0 {
+				t.Errorf("フレーム %d: バッファ %d を更新しうる", i, buf)
+			}
+		}
+		// 同期フレームは TL0 (LAST) だけを参照する
+		if p.LayerSync && flags&(vpxext.EflagNoRefGf|vpxext.EflagNoRefArf) != vpxext.EflagNoRefGf|vpxext.EflagNoRefArf {
+			t.Errorf("フレーム %d: 同期フレームが GOLDEN/ALTREF を参照しうる", i)
+		}
+	}
+	if tl.tl0PicIdx != 1 {
+		t.Errorf("TL0PICIDX %d, want 1", tl.tl0PicIdx)
+	}
+}
+
+func TestTemporalLayersAutoKeyframe(t *testing.T) {
+	tl := newTestTemporalLayers(t, 3)
+	layer, _ := tl.next(false)
+	p := Packet{Keyframe: true}
+	tl.annotate(&p, layer)
+
+	// パターン上はレイヤー 2 のフレームを libvpx がキーフレームにした場合
+	layer, _ = tl.next(false)
+	if layer != 2 {
+		t.Fatalf("レイヤー %d, want 2", layer)
+	}
+	p = Packet{Keyframe: true}
+	tl.annotate(&p, layer)
+	if p.TemporalLayerID != 0 || p.TL0PicIdx != 1 || p.LayerSync {
+		t.Fatalf("キーフレーム: TID %d TL0PICIDX %d sync %v, want 0 1 false", p.TemporalLayerID, p.TL0PicIdx, p.LayerSync)
+	}
+	// 次のフレームはパターンの 2 番目 (レイヤー 2 の同期フレーム) から始まる
+	layer, flags := tl.next(false)
+	p = Packet{}
+	tl.annotate(&p, layer)
+	if layer != 2 || !p.LayerSync || flags&vpxext.EflagNoRefGf == 0 || flags&vpxext.EflagNoRefArf == 0 {
+		t.Fatalf("キーフレーム後: レイヤー %d sync %v", layer, p.LayerSync)
+	}
+}
@@ -0,0 +1,262 @@
+package vpxgo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/xlab/libvpx-go/vpx"
+	"gocv.io/x/gocv"
+)
+
+// TranscodeOptions controls Transcode.
+type TranscodeOptions struct {
+	Codec       Codec
+	BitrateKbps int
+	CpuUsed     int
+	// Config は FileEncodeOptions.Config と同じく、指定すると Codec, BitrateKbps, CpuUsed の代わりに使う
+	Config *EncoderConfig
+	// SceneCut は場面転換にキーフレームを挿入する (EncodeFile と同じ検出器)
+	SceneCut bool
+
+	// StartFrame から EndFrame の手前までを変換する (EndFrame が 0 なら最後まで)。
+	// 中断した変換は TranscodeResult.NextFrame から別ファイルとして再開できる。
+	StartFrame int
+	EndFrame   int
+
+	// Progress は ProgressInterval ごと (既定 1 秒) と終了時に呼ばれる
+	Progress         func(TranscodeProgress)
+	ProgressInterval time.Duration
+}
+
+// TranscodeProgress reports the state of a running Transcode.
+type TranscodeProgress struct {
+	Frame   int           // 次に読むソースフレームの番号
+	Total   int           // 変換範囲の終わり (不明なら 0)
+	Elapsed time.Duration // 経過時間
+	FPS     float64       // 変換速度 (フレーム/秒)
+	ETA     time.Duration // 残り時間の見積もり (不明なら 0)
+	Bytes   int64         // 出力済みのパケットサイズ
+}
+
+// TranscodeResult summarises a finished or interrupted Transcode.
+type TranscodeResult struct {
+	Frames    int           // 変換したフレーム数
+	NextFrame int           // 続きを変換する場合の StartFrame
+	Duration  time.Duration // 出力の長さ (WebM の Duration と同じ値)
+	Bytes     int64
+	// Interrupted は ctx のキャンセルで途中終了したことを示す (出力ファイルは有効)
+	Interrupted bool
+}
+
+// Transcode decodes any video file that OpenCV (FFmpeg backend) can read,
+// encodes the frames with this package's encoder and muxes them into a WebM
+// file. Frame timestamps are taken from the source, so variable frame rate
+// input keeps its timing; the output starts at 0 even when StartFrame > 0.
+// When ctx is cancelled the file is finalised and the result tells where to
+// resume.
+func Transcode(ctx context.Context, srcPath, outPath string, opts TranscodeOptions) (TranscodeResult, error) {
+	res := TranscodeResult{NextFrame: opts.StartFrame}
+	if opts.StartFrame < 0 || (opts.EndFrame > 0 && opts.EndFrame <= opts.StartFrame) {
+		return res, fmt.Errorf("フレーム範囲が不正です: %d-%d", opts.StartFrame, opts.EndFrame)
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = time.Second
+	}
+
+	vc, err := gocv.VideoCaptureFile(srcPath)
+	if err != nil {
+		return res, fmt.Errorf("ソース動画オープンエラー: %v", err)
+	}
+	defer vc.Close()
+	mat := gocv.NewMat()
+	defer mat.Close()
+
+	fps := vc.Get(gocv.VideoCaptureFPS)
+	if fps <= 0 || math.IsNaN(fps) {
+		fps = 30
+	}
+	total := int(vc.Get(gocv.VideoCaptureFrameCount))
+	if opts.EndFrame > 0 && (total <= 0 || opts.EndFrame < total) {
+		total = opts.EndFrame
+	}
+	if err := seekFrame(vc, &mat, opts.StartFrame); err != nil {
+		return res, err
+	}
+
+	// read は次のフレームとソース上の時刻 (ミリ秒) を返す。終端では nil。
+	frameMs := 1000 / fps
+	var prevMs float64 = -1
+	read := func(index int) (*I420Frame, int64, error) {
+		if opts.EndFrame > 0 && index >= opts.EndFrame {
+			return nil, 0, nil
+		}
+		if ok := vc.Read(&mat); !ok || mat.Empty() {
+			return nil, 0, nil
+		}
+		// POS_MSEC は直前に読んだフレームの時刻。取得できないか戻る場合はフレームレートから補う
+		ms := vc.Get(gocv.VideoCapturePosMsec)
+		if prevMs >= 0 && ms <= prevMs {
+			ms = prevMs + frameMs
+		}
+		prevMs = ms
+		f, err := MatToI420(mat)
+		return f, int64(math.Round(ms)), err
+	}
+
+	frame, frameAt, err := read(opts.StartFrame)
+	if err != nil {
+		return res, err
+	}
+	if frame == nil {
+		return res, fmt.Errorf("フレーム %d 以降を読み込めません", opts.StartFrame)
+	}
+	firstMs := frameAt
+
+	cfg := fileEncoderConfig(opts.Config, opts.Codec, opts.BitrateKbps, opts.CpuUsed, frame.Width, frame.Height, fps)
+	var scenes *SceneDetector
+	if opts.SceneCut {
+		scenes = NewSceneDetector(SceneDetectorConfig{FPS: fps})
+		if cfg.KeyframeMaxDist == 0 {
+			cfg.KeyframeMaxDist = cfg.FPS * 10
+		}
+	}
+	enc, err := NewEncoder(cfg)
+	if err != nil {
+		return res, err
+	}
+	defer enc.Close()
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	// PTS をミリ秒で渡すためタイムベースを 1/1000 にする
+	ww, err := NewWebMWriterTimebase(f, cfg.Codec, frame.Width, frame.Height, 1, 1000)
+	if err != nil {
+		return res, err
+	}
+	// エラーで中断しても書き込めた分を再生できるよう、サイズと長さを確定させる
+	finished := false
+	defer func() {
+		if !finished {
+			ww.Close()
+		}
+	}()
+
+	// エンコーダーの PTS (入力順の番号) からソースの時刻への対応
+	times := make(map[int64]int64)
+	frameDur := uint64(max(math.Round(frameMs), 1))
+	write := func(pkts []Packet) error {
+		for _, p := range pkts {
+			ms, ok := times[p.PTS]
+			if !ok {
+				ms = int64(float64(p.PTS) * frameMs)
+			}
+			// 同じ PTS のパケット (VP8 の非表示 alt-ref など) が続くことがあるので古いものだけ消す
+			for k := range times {
+				if k < p.PTS {
+					delete(times, k)
+				}
+			}
+			p.PTS = ms
+			// Duration も PTS と同じミリ秒単位で、ソースの 1 フレームの長さにする
+			p.Duration = frameDur
+			if err := ww.WritePacket(p); err != nil {
+				return err
+			}
+			res.Bytes += int64(len(p.Data))
+		}
+		return nil
+	}
+
+	start := time.Now()
+	lastReport := start
+	report := func() {
+		if opts.Progress == nil {
+			return
+		}
+		elapsed := time.Since(start)
+		pr := TranscodeProgress{Frame: res.NextFrame, Total: total, Elapsed: elapsed, Bytes: res.Bytes}
+		if elapsed > 0 {
+			pr.FPS = float64(res.Frames) / elapsed.Seconds()
+		}
+		if total > res.NextFrame && pr.FPS > 0 {
+			pr.ETA = time.Duration(float64(total-res.NextFrame) / pr.FPS * float64(time.Second))
+		}
+		opts.Progress(pr)
+	}
+
+	var pts int64
+	for frame != nil {
+		var flags vpx.EncFrameFlags
+		if scenes != nil && scenes.Check(frame) {
+			flags |= vpx.EflagForceKf
+		}
+		times[pts] = frameAt - firstMs
+		pts++
+		pkts, err := enc.EncodeI420(frame, flags)
+		if err != nil {
+			return res, err
+		}
+		if err := write(pkts); err != nil {
+			return res, err
+		}
+		res.Frames++
+		res.NextFrame++
+		if time.Since(lastReport) >= opts.ProgressInterval {
+			report()
+			lastReport = time.Now()
+		}
+
+		if ctx.Err() != nil {
+			res.Interrupted = true
+			break
+		}
+		if frame, frameAt, err = read(res.NextFrame); err != nil {
+			return res, err
+		}
+	}
+
+	pkts, err := enc.Flush()
+	if err != nil {
+		return res, err
+	}
+	if err := write(pkts); err != nil {
+		return res, err
+	}
+	finished = true
+	if err := ww.Close(); err != nil {
+		return res, err
+	}
+	if err := f.Close(); err != nil {
+		return res, err
+	}
+	res.Duration = ww.Duration()
+	report()
+	return res, nil
+}
+
+// seekFrame は次に読むフレームを index にします。コンテナによってはシークが
+// 不正確または未対応なので、位置を確かめて足りない分は読み飛ばす。
+func seekFrame(vc *gocv.VideoCapture, mat *gocv.Mat, index int) error {
+	if index == 0 {
+		return nil
+	}
+	vc.Set(gocv.VideoCapturePosFrames, float64(index))
+	pos := int(vc.Get(gocv.VideoCapturePosFrames))
+	if pos > index || pos < 0 {
+		// 行き過ぎた場合は先頭に戻して読み飛ばす
+		vc.Set(gocv.VideoCapturePosFrames, 0)
+		pos = int(vc.Get(gocv.VideoCapturePosFrames))
+	}
+	for ; pos < index; pos++ {
+		if ok := vc.Read(mat); !ok || mat.Empty() {
+			return fmt.Errorf("開始フレーム %d がソースの範囲外です (%d フレーム)", index, pos)
+		}
+	}
+	return nil
+}
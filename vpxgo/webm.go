@@ -0,0 +1,768 @@
+package vpxgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// WebM (Matroska) の要素 ID
+const (
+	ebmlIDSegment       = 0x18538067
+	ebmlIDInfo          = 0x1549A966
+	ebmlIDTimecodeScale = 0x2AD7B1
+	ebmlIDTracks        = 0x1654AE6B
+	ebmlIDTrackEntry    = 0xAE
+	ebmlIDTrackNumber   = 0xD7
+	ebmlIDTrackType     = 0x83
+	ebmlIDCodecID       = 0x86
+	ebmlIDVideo         = 0xE0
+	ebmlIDPixelWidth    = 0xB0
+	ebmlIDPixelHeight   = 0xBA
+	ebmlIDCluster       = 0x1F43B675
+	ebmlIDTimecode      = 0xE7
+	ebmlIDSimpleBlock   = 0xA3
+	ebmlIDBlockGroup    = 0xA0
+	ebmlIDBlock         = 0xA1
+
+	// アルファチャンネル (BlockAdditional) 関連
+	ebmlIDAlphaMode       = 0x53C0
+	ebmlIDBlockAdditions  = 0x75A1
+	ebmlIDBlockMore       = 0xA6
+	ebmlIDBlockAddID      = 0xEE
+	ebmlIDBlockAdditional = 0xA5
+	ebmlIDReferenceBlock  = 0xFB
+)
+
+// webmAlphaAddID は VP8/VP9 のアルファストリームを格納する BlockAddID です。
+const webmAlphaAddID = 1
+
+// ebmlUnknownSize はライブ配信などでサイズ未確定の要素を表します。
+const ebmlUnknownSize = math.MaxUint64
+
+// WebMReader is a minimal streaming WebM demuxer that returns the frames of
+// the first VP8/VP9 video track. Lacing is not supported. Alpha streams stored
+// as BlockAdditional are returned in ContainerPacket.Alpha.
+type WebMReader struct {
+	r             *bufio.Reader
+	info          StreamInfo
+	track         uint64
+	timecodeScale uint64 // ns
+	clusterTime   int64
+}
+
+// NewWebMReader parses the WebM header from r up to the first VP8/VP9 video
+// track.
+func NewWebMReader(r io.Reader) (*WebMReader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	w := &WebMReader{r: br, timecodeScale: 1000000}
+
+	// 映像トラックが見つかるまでヘッダを解析する (それ以前のブロックは捨てる)
+	for w.track == 0 {
+		if _, err := w.next(); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("WebM に VP8/VP9 映像トラックがありません")
+			}
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *WebMReader) Info() StreamInfo { return w.info }
+
+func (w *WebMReader) ReadPacket() (ContainerPacket, error) {
+	for {
+		pkt, err := w.next()
+		if err != nil {
+			return ContainerPacket{}, err
+		}
+		if pkt != nil {
+			return *pkt, nil
+		}
+	}
+}
+
+// next は要素を 1 つ処理し、対象トラックのブロックであればパケットを返します。
+// マスター要素は中身をそのまま読み進めるため、サイズ未確定でも扱えます。
+func (w *WebMReader) next() (*ContainerPacket, error) {
+	id, size, err := w.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	switch id {
+	case ebmlIDSegment, ebmlIDCluster, ebmlIDTracks, ebmlIDInfo:
+		return nil, nil
+	case ebmlIDBlockGroup:
+		return w.readBlockGroup(size)
+	case ebmlIDTrackEntry:
+		return nil, w.readTrackEntry(size)
+	case ebmlIDTimecodeScale:
+		v, err := w.readUint(size)
+		if v > 0 {
+			w.timecodeScale = v
+		}
+		return nil, err
+	case ebmlIDTimecode:
+		v, err := w.readUint(size)
+		w.clusterTime = int64(v)
+		return nil, err
+	case ebmlIDSimpleBlock, ebmlIDBlock:
+		return w.readBlock(size, id == ebmlIDSimpleBlock)
+	}
+	return nil, w.skip(size)
+}
+
+func (w *WebMReader) readTrackEntry(size uint64) error {
+	if size == ebmlUnknownSize {
+		return fmt.Errorf("TrackEntry のサイズが不明です")
+	}
+	var number, trackType, width, height, alphaMode uint64
+	var codecID string
+	end := size
+	for end > 0 {
+		id, n, hdr, err := w.readHeaderLen()
+		if err != nil {
+			return err
+		}
+		end -= uint64(hdr)
+		switch id {
+		case ebmlIDVideo:
+			// Video は中身 (PixelWidth/PixelHeight) をそのまま読む
+			continue
+		case ebmlIDTrackNumber:
+			number, err = w.readUint(n)
+		case ebmlIDTrackType:
+			trackType, err = w.readUint(n)
+		case ebmlIDPixelWidth:
+			width, err = w.readUint(n)
+		case ebmlIDPixelHeight:
+			height, err = w.readUint(n)
+		case ebmlIDAlphaMode:
+			alphaMode, err = w.readUint(n)
+		case ebmlIDCodecID:
+			buf := make([]byte, n)
+			_, err = io.ReadFull(w.r, buf)
+			codecID = string(buf)
+		default:
+			err = w.skip(n)
+		}
+		if err != nil {
+			return err
+		}
+		end -= n
+	}
+
+	if w.track != 0 || (trackType != 0 && trackType != 1) {
+		return nil
+	}
+	switch codecID {
+	case "V_VP8":
+		w.info.Codec = CodecVP8
+	case "V_VP9":
+		w.info.Codec = CodecVP9
+	default:
+		return nil
+	}
+	w.track = number
+	w.info.Width, w.info.Height = int(width), int(height)
+	w.info.Alpha = alphaMode == 1
+	return nil
+}
+
+// readBlockGroup は BlockGroup 内の Block と、あればアルファ用の BlockAdditional を読みます。
+func (w *WebMReader) readBlockGroup(size uint64) (*ContainerPacket, error) {
+	if size == ebmlUnknownSize {
+		return nil, fmt.Errorf("BlockGroup のサイズが不明です")
+	}
+	var pkt *ContainerPacket
+	var additional []byte
+	addID := uint64(1)
+	end := size
+	for end > 0 {
+		id, n, hdr, err := w.readHeaderLen()
+		if err != nil {
+			return nil, err
+		}
+		end -= uint64(hdr)
+		switch id {
+		case ebmlIDBlockAdditions, ebmlIDBlockMore:
+			// 中身 (BlockAddID/BlockAdditional) をそのまま読む
+			continue
+		case ebmlIDBlock:
+			pkt, err = w.readBlock(n, false)
+		case ebmlIDBlockAddID:
+			addID, err = w.readUint(n)
+		case ebmlIDBlockAdditional:
+			additional = make([]byte, n)
+			_, err = io.ReadFull(w.r, additional)
+		default:
+			err = w.skip(n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		end -= n
+	}
+	if pkt != nil && addID == webmAlphaAddID {
+		pkt.Alpha = additional
+	}
+	return pkt, nil
+}
+
+func (w *WebMReader) readBlock(size uint64, simple bool) (*ContainerPacket, error) {
+	if size == ebmlUnknownSize {
+		return nil, fmt.Errorf("ブロックのサイズが不明です")
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(w.r, buf); err != nil {
+		return nil, fmt.Errorf("WebM ブロック読み込みエラー: %v", err)
+	}
+	track, n := ebmlVint(buf)
+	if n == 0 || len(buf) < n+3 {
+		return nil, fmt.Errorf("不正な WebM ブロックです")
+	}
+	if track != w.track {
+		return nil, nil
+	}
+	rel := int16(binary.BigEndian.Uint16(buf[n : n+2]))
+	flags := buf[n+2]
+	if flags&0x06 != 0 {
+		return nil, fmt.Errorf("レーシングされたブロックには未対応です")
+	}
+
+	ticks := w.clusterTime + int64(rel)
+	pkt := &ContainerPacket{
+		Data:      buf[n+3:],
+		Timestamp: time.Duration(ticks * int64(w.timecodeScale)),
+	}
+	if simple {
+		pkt.Keyframe = flags&0x80 != 0
+	} else {
+		pkt.Keyframe = isKeyframe(w.info.Codec, pkt.Data)
+	}
+	return pkt, nil
+}
+
+func (w *WebMReader) readHeader() (id uint32, size uint64, err error) {
+	id, size, _, err = w.readHeaderLen()
+	return
+}
+
+// readHeaderLen は要素 ID とサイズを読み、ヘッダ自体のバイト数も返します。
+func (w *WebMReader) readHeaderLen() (uint32, uint64, int, error) {
+	first, err := w.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	idLen := ebmlLength(first)
+	if idLen == 0 || idLen > 4 {
+		return 0, 0, 0, fmt.Errorf("不正な EBML 要素 ID です")
+	}
+	id := uint32(first)
+	for i := 1; i < idLen; i++ {
+		b, err := w.r.ReadByte()
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("EBML 読み込みエラー: %v", err)
+		}
+		id = id<<8 | uint32(b)
+	}
+
+	first, err = w.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("EBML 読み込みエラー: %v", err)
+	}
+	sizeLen := ebmlLength(first)
+	if sizeLen == 0 {
+		return 0, 0, 0, fmt.Errorf("不正な EBML サイズです")
+	}
+	size := uint64(first) & (0xff >> sizeLen)
+	allOnes := size == uint64(0xff>>sizeLen)
+	for i := 1; i < sizeLen; i++ {
+		b, err := w.r.ReadByte()
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("EBML 読み込みエラー: %v", err)
+		}
+		size = size<<8 | uint64(b)
+		allOnes = allOnes && b == 0xff
+	}
+	if allOnes {
+		size = ebmlUnknownSize
+	}
+	return id, size, idLen + sizeLen, nil
+}
+
+func (w *WebMReader) readUint(size uint64) (uint64, error) {
+	if size > 8 {
+		return 0, fmt.Errorf("不正な EBML 整数サイズ: %d", size)
+	}
+	var v uint64
+	for i := uint64(0); i < size; i++ {
+		b, err := w.r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("EBML 読み込みエラー: %v", err)
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func (w *WebMReader) skip(size uint64) error {
+	if size == ebmlUnknownSize {
+		return fmt.Errorf("サイズ不明の要素はスキップできません")
+	}
+	if _, err := w.r.Discard(int(size)); err != nil {
+		return fmt.Errorf("EBML 読み込みエラー: %v", err)
+	}
+	return nil
+}
+
+// ebmlLength は先頭バイトの先行ゼロから可変長整数のバイト数を求めます。
+func ebmlLength(first byte) int {
+	for i := 0; i < 8; i++ {
+		if first&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// ebmlVint はマーカービットを除いた可変長整数とそのバイト数を返します。
+func ebmlVint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	n := ebmlLength(b[0])
+	if n == 0 || len(b) < n {
+		return 0, 0
+	}
+	v := uint64(b[0]) & (0xff >> n)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n
+}
+
+// WebM の書き出しで追加で使う要素 ID
+const (
+	ebmlIDEBMLVersion        = 0x4286
+	ebmlIDEBMLReadVersion    = 0x42F7
+	ebmlIDEBMLMaxIDLength    = 0x42F2
+	ebmlIDEBMLMaxSizeLength  = 0x42F3
+	ebmlIDDocType            = 0x4282
+	ebmlIDDocTypeVersion     = 0x4287
+	ebmlIDDocTypeReadVersion = 0x4285
+	ebmlIDHeader             = 0x1A45DFA3
+	ebmlIDMuxingApp          = 0x4D80
+	ebmlIDWritingApp         = 0x5741
+	ebmlIDDuration           = 0x4489
+	ebmlIDTrackUID           = 0x73C5
+	ebmlIDVoid               = 0xEC
+
+	// シーク用の索引 (シーク可能な出力のみ)
+	ebmlIDSeekHead           = 0x114D9B74
+	ebmlIDSeek               = 0x4DBB
+	ebmlIDSeekID             = 0x53AB
+	ebmlIDSeekPosition       = 0x53AC
+	ebmlIDCues               = 0x1C53BB6B
+	ebmlIDCuePoint           = 0xBB
+	ebmlIDCueTime            = 0xB3
+	ebmlIDCueTrackPositions  = 0xB7
+	ebmlIDCueTrack           = 0xF7
+	ebmlIDCueClusterPosition = 0xF1
+)
+
+// webmMaxClusterMs はクラスタ内の相対タイムコード (int16) に収まる長さです。
+const webmMaxClusterMs = 30000
+
+// webmSeekHeadSize は Cues を指す Seek を 1 つだけ持つ SeekHead の長さです
+// (ID 4 + サイズ 1 + Seek 2+1 + SeekID 2+1+4 + SeekPosition 2+1+8)。
+const webmSeekHeadSize = 26
+
+// WebMWriter muxes encoded VP8/VP9 packets into a WebM file with a single
+// video track. A new cluster is started at every keyframe. Writers created
+// with NewAlphaWebMWriter also carry an alpha stream per frame. When the
+// underlying writer is an io.WriteSeeker the segment and cluster sizes, the
+// duration and a Cues index of the keyframe clusters are filled in on Close;
+// otherwise unknown sizes are used, which players accept for live streams.
+type WebMWriter struct {
+	w  io.Writer
+	ws io.WriteSeeker
+	// 1 PTS は tbNum/tbDen 秒
+	tbNum, tbDen int64
+
+	off          int64 // 書き込んだバイト数
+	segmentStart int64 // Segment のデータ先頭
+	seekHeadOff  int64 // SeekHead の先頭 (シーク可能な場合のみ)
+	durationOff  int64
+	clusterStart int64 // 現在のクラスタのサイズ欄の位置 (-1: クラスタなし)
+	clusterMs    int64
+	lastMs       int64
+	prevMs       int64 // 直前のブロックの時刻 (ReferenceBlock 用)
+	cues         []webmCue
+}
+
+// webmCue はキーフレームで始まるクラスタの時刻と位置 (Segment のデータ先頭から) です。
+type webmCue struct {
+	ms  int64
+	pos int64
+}
+
+// NewWebMWriter writes the EBML header, segment info and track entry. The
+// packet PTS is interpreted in units of 1/fps as produced by Encoder.
+func NewWebMWriter(w io.Writer, codec Codec, width, height, fps int) (*WebMWriter, error) {
+	return newWebMWriter(w, codec, width, height, 1, fps, false)
+}
+
+// NewWebMWriterTimebase is like NewWebMWriter but takes the packet timebase
+// as a fraction: one PTS unit is num/den seconds (e.g. 1001/30000 for 29.97
+// fps), so that non-integer frame rates do not drift.
+func NewWebMWriterTimebase(w io.Writer, codec Codec, width, height, num, den int) (*WebMWriter, error) {
+	return newWebMWriter(w, codec, width, height, num, den, false)
+}
+
+// NewAlphaWebMWriter is like NewWebMWriter but marks the track with
+// AlphaMode so that WritePacketAlpha can attach an alpha stream to each frame.
+func NewAlphaWebMWriter(w io.Writer, codec Codec, width, height, fps int) (*WebMWriter, error) {
+	return newWebMWriter(w, codec, width, height, 1, fps, true)
+}
+
+func newWebMWriter(w io.Writer, codec Codec, width, height, num, den int, alpha bool) (*WebMWriter, error) {
+	if num <= 0 || den <= 0 {
+		return nil, fmt.Errorf("タイムベースが不正です: %d/%d", num, den)
+	}
+	ww := &WebMWriter{w: w, tbNum: int64(num), tbDen: int64(den), clusterStart: -1}
+	ww.ws, _ = w.(io.WriteSeeker)
+
+	header := ebmlMaster(ebmlIDHeader,
+		ebmlUintElem(ebmlIDEBMLVersion, 1),
+		ebmlUintElem(ebmlIDEBMLReadVersion, 1),
+		ebmlUintElem(ebmlIDEBMLMaxIDLength, 4),
+		ebmlUintElem(ebmlIDEBMLMaxSizeLength, 8),
+		ebmlStringElem(ebmlIDDocType, "webm"),
+		ebmlUintElem(ebmlIDDocTypeVersion, 4),
+		ebmlUintElem(ebmlIDDocTypeReadVersion, 2),
+	)
+	if err := ww.write(header); err != nil {
+		return nil, err
+	}
+
+	// Segment はサイズ未確定で開始し、シーク可能なら Close で埋める
+	if err := ww.write(append(ebmlID(ebmlIDSegment), ebmlUnknownSizeBytes...)); err != nil {
+		return nil, err
+	}
+	ww.segmentStart = ww.off
+
+	if ww.ws != nil {
+		// Cues は Close で末尾に書くので、その位置を後から埋める SeekHead を置く
+		seekHead := ebmlMaster(ebmlIDSeekHead,
+			ebmlMaster(ebmlIDSeek,
+				ebmlElem(ebmlIDSeekID, ebmlID(ebmlIDCues)),
+				ebmlElem(ebmlIDSeekPosition, make([]byte, 8)),
+			),
+		)
+		ww.seekHeadOff = ww.off
+		if err := ww.write(seekHead); err != nil {
+			return nil, err
+		}
+	}
+
+	info := [][]byte{
+		ebmlUintElem(ebmlIDTimecodeScale, 1000000),
+		ebmlStringElem(ebmlIDMuxingApp, "libvpxGo"),
+		ebmlStringElem(ebmlIDWritingApp, "libvpxGo"),
+	}
+	if ww.ws != nil {
+		info = append(info, ebmlFloatElem(ebmlIDDuration, 0))
+	}
+	infoElem := ebmlMaster(ebmlIDInfo, info...)
+	if ww.ws != nil {
+		// Duration は Info の末尾 8 バイト
+		ww.durationOff = ww.off + int64(len(infoElem)) - 8
+	}
+	if err := ww.write(infoElem); err != nil {
+		return nil, err
+	}
+
+	codecID := "V_VP8"
+	if codec == CodecVP9 {
+		codecID = "V_VP9"
+	}
+	video := [][]byte{
+		ebmlUintElem(ebmlIDPixelWidth, uint64(width)),
+		ebmlUintElem(ebmlIDPixelHeight, uint64(height)),
+	}
+	if alpha {
+		video = append(video, ebmlUintElem(ebmlIDAlphaMode, 1))
+	}
+	tracks := ebmlMaster(ebmlIDTracks,
+		ebmlMaster(ebmlIDTrackEntry,
+			ebmlUintElem(ebmlIDTrackNumber, 1),
+			ebmlUintElem(ebmlIDTrackUID, 1),
+			ebmlUintElem(ebmlIDTrackType, 1),
+			ebmlStringElem(ebmlIDCodecID, codecID),
+			ebmlMaster(ebmlIDVideo, video...),
+		),
+	)
+	if err := ww.write(tracks); err != nil {
+		return nil, err
+	}
+	return ww, nil
+}
+
+// WritePacket appends one frame as a SimpleBlock.
+func (ww *WebMWriter) WritePacket(p Packet) error {
+	return ww.WritePacketAlpha(p, nil)
+}
+
+// WritePacketAlpha appends one frame together with the matching frame of the
+// alpha stream, stored as a BlockGroup with BlockAdditional (BlockAddID 1).
+// With empty alpha data it behaves like WritePacket.
+func (ww *WebMWriter) WritePacketAlpha(p Packet, alpha []byte) error {
+	ms := ww.ptsMs(p.PTS)
+	if ww.clusterStart < 0 || p.Keyframe || ms-ww.clusterMs > webmMaxClusterMs {
+		if err := ww.startCluster(ms, p.Keyframe); err != nil {
+			return err
+		}
+	}
+
+	var flags byte
+	if len(alpha) == 0 {
+		// SimpleBlock のみキーフレーム/破棄可能フラグを持つ
+		if p.Keyframe {
+			flags |= 0x80
+		}
+		if p.Droppable {
+			flags |= 0x01
+		}
+	}
+	rel := ms - ww.clusterMs
+	block := make([]byte, 0, len(p.Data)+4)
+	block = append(block, 0x81, byte(rel>>8), byte(rel), flags) // トラック番号 1
+	block = append(block, p.Data...)
+
+	var elem []byte
+	if len(alpha) == 0 {
+		elem = ebmlElem(ebmlIDSimpleBlock, block)
+	} else {
+		group := [][]byte{ebmlElem(ebmlIDBlock, block)}
+		group = append(group, ebmlMaster(ebmlIDBlockAdditions,
+			ebmlMaster(ebmlIDBlockMore,
+				ebmlUintElem(ebmlIDBlockAddID, webmAlphaAddID),
+				ebmlElem(ebmlIDBlockAdditional, alpha),
+			),
+		))
+		if !p.Keyframe {
+			// ReferenceBlock があるとキーフレームではないことを示す
+			group = append(group, ebmlIntElem(ebmlIDReferenceBlock, ww.prevMs-ms))
+		}
+		elem = ebmlMaster(ebmlIDBlockGroup, group...)
+	}
+	if err := ww.write(elem); err != nil {
+		return err
+	}
+	ww.prevMs = ms
+	// 表示の終わり。Duration が 0 のパケットは 1 PTS 単位とみなす
+	dur := int64(p.Duration)
+	if dur <= 0 {
+		dur = 1
+	}
+	ww.lastMs = max(ww.lastMs, ww.ptsMs(p.PTS+dur))
+	return nil
+}
+
+// ptsMs は PTS をミリ秒 (TimecodeScale) に丸めます。
+func (ww *WebMWriter) ptsMs(pts int64) int64 {
+	return (pts*1000*ww.tbNum + ww.tbDen/2) / ww.tbDen
+}
+
+// Duration returns the end time of the last frame written so far.
+func (ww *WebMWriter) Duration() time.Duration {
+	return time.Duration(ww.lastMs) * time.Millisecond
+}
+
+func (ww *WebMWriter) startCluster(ms int64, keyframe bool) error {
+	if err := ww.finishCluster(); err != nil {
+		return err
+	}
+	if ww.ws != nil && keyframe {
+		ww.cues = append(ww.cues, webmCue{ms: ms, pos: ww.off - ww.segmentStart})
+	}
+	if err := ww.write(append(ebmlID(ebmlIDCluster), ebmlUnknownSizeBytes...)); err != nil {
+		return err
+	}
+	ww.clusterStart = ww.off - int64(len(ebmlUnknownSizeBytes))
+	ww.clusterMs = ms
+	return ww.write(ebmlUintElem(ebmlIDTimecode, uint64(ms)))
+}
+
+// finishCluster は現在のクラスタのサイズを確定させます (シーク可能な場合のみ)。
+func (ww *WebMWriter) finishCluster() error {
+	if ww.clusterStart < 0 || ww.ws == nil {
+		return nil
+	}
+	size := ww.off - ww.clusterStart - int64(len(ebmlUnknownSizeBytes))
+	return ww.patch(ww.clusterStart, ebmlSize8(uint64(size)))
+}
+
+// Close finalises sizes, the duration and the Cues when possible. It does not
+// close the underlying writer.
+func (ww *WebMWriter) Close() error {
+	if ww.ws == nil {
+		return nil
+	}
+	if err := ww.finishCluster(); err != nil {
+		return err
+	}
+	if err := ww.writeCues(); err != nil {
+		return err
+	}
+	if err := ww.patch(ww.segmentStart-8, ebmlSize8(uint64(ww.off-ww.segmentStart))); err != nil {
+		return err
+	}
+	var d [8]byte
+	binary.BigEndian.PutUint64(d[:], math.Float64bits(float64(ww.lastMs)))
+	return ww.patch(ww.durationOff, d[:])
+}
+
+// writeCues はキーフレームのクラスタの索引を末尾に書き、SeekHead から参照させます。
+// キーフレームがなければ SeekHead を Void に置き換える。
+func (ww *WebMWriter) writeCues() error {
+	seekPosOff := ww.seekHeadOff + webmSeekHeadSize - 8
+	if len(ww.cues) == 0 {
+		void := append(ebmlID(ebmlIDVoid), ebmlSize8(webmSeekHeadSize-9)...)
+		return ww.patch(ww.seekHeadOff, void)
+	}
+	points := make([][]byte, len(ww.cues))
+	for i, c := range ww.cues {
+		points[i] = ebmlMaster(ebmlIDCuePoint,
+			ebmlUintElem(ebmlIDCueTime, uint64(c.ms)),
+			ebmlMaster(ebmlIDCueTrackPositions,
+				ebmlUintElem(ebmlIDCueTrack, 1),
+				ebmlUintElem(ebmlIDCueClusterPosition, uint64(c.pos)),
+			),
+		)
+	}
+	pos := ww.off - ww.segmentStart
+	if err := ww.write(ebmlMaster(ebmlIDCues, points...)); err != nil {
+		return err
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(pos))
+	return ww.patch(seekPosOff, b[:])
+}
+
+func (ww *WebMWriter) write(b []byte) error {
+	n, err := ww.w.Write(b)
+	ww.off += int64(n)
+	if err != nil {
+		return fmt.Errorf("WebM 書き込みエラー: %v", err)
+	}
+	return nil
+}
+
+func (ww *WebMWriter) patch(off int64, b []byte) error {
+	if _, err := ww.ws.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := ww.ws.Write(b); err != nil {
+		return fmt.Errorf("WebM 書き込みエラー: %v", err)
+	}
+	_, err := ww.ws.Seek(ww.off, io.SeekStart)
+	return err
+}
+
+// ebmlUnknownSizeBytes はサイズ未確定を表す 8 バイトのサイズ欄です。
+var ebmlUnknownSizeBytes = []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+func ebmlID(id uint32) []byte {
+	switch {
+	case id >= 1<<24:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 1<<16:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 1<<8:
+		return []byte{byte(id >> 8), byte(id)}
+	}
+	return []byte{byte(id)}
+}
+
+// ebmlSize は最短の可変長整数でサイズを符号化します。
+func ebmlSize(n uint64) []byte {
+	for l := 1; l < 8; l++ {
+		if n < 1<<(7*l)-1 {
+			b := make([]byte, l)
+			for i := l - 1; i >= 0; i-- {
+				b[i] = byte(n)
+				n >>= 8
+			}
+			b[0] |= 0x80 >> (l - 1)
+			return b
+		}
+	}
+	return ebmlSize8(n)
+}
+
+// ebmlSize8 は後から上書きできるよう常に 8 バイトでサイズを符号化します。
+func ebmlSize8(n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	b[0] = 0x01
+	return b[:]
+}
+
+func ebmlElem(id uint32, data []byte) []byte {
+	out := append(ebmlID(id), ebmlSize(uint64(len(data)))...)
+	return append(out, data...)
+}
+
+func ebmlMaster(id uint32, children ...[]byte) []byte {
+	var data []byte
+	for _, c := range children {
+		data = append(data, c...)
+	}
+	return ebmlElem(id, data)
+}
+
+func ebmlUintElem(id uint32, v uint64) []byte {
+	n := 1
+	for v>>(8*n) != 0 && n < 8 {
+		n++
+	}
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return ebmlElem(id, b)
+}
+
+// ebmlIntElem は符号付き整数要素を 2 の補数の最短バイト数で符号化します。
+func ebmlIntElem(id uint32, v int64) []byte {
+	n := 1
+	for n < 8 && (v < -(1<<(8*n-1)) || v >= 1<<(8*n-1)) {
+		n++
+	}
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return ebmlElem(id, b)
+}
+
+func ebmlStringElem(id uint32, s string) []byte {
+	return ebmlElem(id, []byte(s))
+}
+
+func ebmlFloatElem(id uint32, v float64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	return ebmlElem(id, b[:])
+}
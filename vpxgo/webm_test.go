@@ -0,0 +1,209 @@
+package vpxgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// webmTestPackets は PTS 0..n-1 のパケットを作ります (10 フレームごとにキーフレーム)。
+// 先頭バイトの最下位ビットは VP8 のフレーム種別 (0 がキーフレーム) に合わせる。
+func webmTestPackets(n int) []Packet {
+	pkts := make([]Packet, n)
+	for i := range pkts {
+		kf := i%10 == 0
+		b0 := byte(i << 1)
+		if !kf {
+			b0 |= 1
+		}
+		pkts[i] = Packet{Data: []byte{b0, 0x55, byte(i * 7)}, PTS: int64(i), Duration: 1, Keyframe: kf}
+	}
+	return pkts
+}
+
+// writeWebMFile は 29.97 fps のタイムベースで pkts を一時ファイルに書き、その内容を返します。
+func writeWebMFile(t *testing.T, pkts []Packet) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out.webm")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ww, err := NewWebMWriterTimebase(f, CodecVP8, 64, 48, 1001, 30000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pkts {
+		if err := ww.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ww.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readWebMPackets(t *testing.T, data []byte) (StreamInfo, []ContainerPacket) {
+	t.Helper()
+	r, err := NewWebMReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ContainerPacket
+	for {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	return r.Info(), got
+}
+
+func TestWebMWriterRoundtrip(t *testing.T) {
+	pkts := webmTestPackets(35)
+
+	var buf bytes.Buffer // シークできない出力 (サイズ未確定)
+	ww, err := NewWebMWriterTimebase(&buf, CodecVP8, 64, 48, 1001, 30000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pkts {
+		if err := ww.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ww.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"stream": buf.Bytes(), "file": writeWebMFile(t, pkts)} {
+		t.Run(name, func(t *testing.T) {
+			info, got := readWebMPackets(t, data)
+			if info.Codec != CodecVP8 || info.Width != 64 || info.Height != 48 {
+				t.Fatalf("Info = %+v", info)
+			}
+			if len(got) != len(pkts) {
+				t.Fatalf("パケット数 %d, want %d", len(got), len(pkts))
+			}
+			for i, p := range got {
+				// 29.97 fps の時刻をミリ秒に丸めた値 (整数 fps で割ると 1 フレームずつずれていく)
+				want := time.Duration(math.Round(float64(i)*1001/30)) * time.Millisecond
+				if p.Timestamp != want {
+					t.Errorf("フレーム %d の時刻 %v, want %v", i, p.Timestamp, want)
+				}
+				if p.Keyframe != pkts[i].Keyframe || !bytes.Equal(p.Data, pkts[i].Data) {
+					t.Errorf("フレーム %d が一致しません", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWebMWriterDurationAndCues(t *testing.T) {
+	data := writeWebMFile(t, webmTestPackets(30))
+
+	i := bytes.Index(data, []byte{0x44, 0x89, 0x88}) // Duration (8 バイトの float)
+	if i < 0 {
+		t.Fatal("Duration がありません")
+	}
+	// 30 フレーム x 1001/30000 秒 = 1001 ms
+	if d := math.Float64frombits(binary.BigEndian.Uint64(data[i+3:])); d != 1001 {
+		t.Errorf("Duration = %v, want 1001", d)
+	}
+
+	// Segment の先頭の SeekHead から Cues をたどる
+	seg := bytes.Index(data, ebmlID(ebmlIDSegment)) + 4 + 8
+	if !bytes.HasPrefix(data[seg:], ebmlID(ebmlIDSeekHead)) {
+		t.Fatal("SeekHead がありません")
+	}
+	pos := int(binary.BigEndian.Uint64(data[seg+webmSeekHeadSize-8:]))
+	r := &WebMReader{r: bufio.NewReader(bytes.NewReader(data[seg+pos:]))}
+	id, size, err := r.readHeader()
+	if err != nil || id != ebmlIDCues {
+		t.Fatalf("SeekHead の位置に Cues がありません: id=%#x err=%v", id, err)
+	}
+	var points int
+	for size > 0 {
+		id, n, hdr, err := r.readHeaderLen()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == ebmlIDCuePoint {
+			points++
+		}
+		if err := r.skip(n); err != nil {
+			t.Fatal(err)
+		}
+		size -= uint64(hdr) + n
+	}
+	if points != 3 {
+		t.Errorf("CuePoint %d 個, want 3 (キーフレームのクラスタごと)", points)
+	}
+}
+
+func TestWebMWriterWithoutKeyframe(t *testing.T) {
+	pkts := webmTestPackets(5)
+	for i := range pkts {
+		pkts[i].Keyframe = false
+	}
+	data := writeWebMFile(t, pkts)
+	// Cues がないので SeekHead は Void に置き換わる
+	seg := bytes.Index(data, ebmlID(ebmlIDSegment)) + 4 + 8
+	if data[seg] != ebmlIDVoid {
+		t.Fatalf("SeekHead が残っています: %#x", data[seg])
+	}
+	if _, got := readWebMPackets(t, data); len(got) != len(pkts) {
+		t.Fatalf("パケット数 %d, want %d", len(got), len(pkts))
+	}
+}
+
+func TestWebMWriterAlphaRoundtrip(t *testing.T) {
+	var buf bytes.Buffer
+	ww, err := NewAlphaWebMWriter(&buf, CodecVP8, 32, 32, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkts := webmTestPackets(12)
+	for i, p := range pkts {
+		var alpha []byte
+		if i != 5 {
+			alpha = []byte{0xa0, byte(i)}
+		}
+		if err := ww.WritePacketAlpha(p, alpha); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, got := readWebMPackets(t, buf.Bytes())
+	if !info.Alpha {
+		t.Fatal("AlphaMode がありません")
+	}
+	for i, p := range got {
+		var want []byte
+		if i != 5 {
+			want = []byte{0xa0, byte(i)}
+		}
+		if !bytes.Equal(p.Alpha, want) || !bytes.Equal(p.Data, pkts[i].Data) {
+			t.Errorf("フレーム %d: alpha %v, want %v", i, p.Alpha, want)
+		}
+		if p.Keyframe != pkts[i].Keyframe {
+			t.Errorf("フレーム %d: Keyframe = %v", i, p.Keyframe)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package vpxgo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Y4MReader reads frames from a YUV4MPEG2 stream.
+type Y4MReader struct {
+	r         *bufio.Reader
+	Width     int
+	Height    int
+	FPSNum    int
+	FPSDen    int
+	BitDepth  int  // 8, 10 または 12
+	Chroma444 bool // false なら 4:2:0
+}
+
+// NewY4MReader parses the stream header. 4:2:0 and 4:4:4 streams with 8, 10
+// or 12 bits are supported (C420, C420jpeg, C420paldv, C420mpeg2, C420p10,
+// C420p12, C444, C444p10, C444p12 or no C tag). ReadFrame handles the 8-bit
+// 4:2:0 case; ReadFrame16 handles all of them.
+func NewY4MReader(r io.Reader) (*Y4MReader, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Y4M ヘッダ読み込みエラー: %v", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "YUV4MPEG2" {
+		return nil, fmt.Errorf("Y4M ファイルではありません")
+	}
+
+	y := &Y4MReader{r: br, FPSNum: 30, FPSDen: 1, BitDepth: 8}
+	for _, f := range fields[1:] {
+		val := f[1:]
+		switch f[0] {
+		case 'W':
+			y.Width, _ = strconv.Atoi(val)
+		case 'H':
+			y.Height, _ = strconv.Atoi(val)
+		case 'F':
+			if n, d, ok := strings.Cut(val, ":"); ok {
+				y.FPSNum, _ = strconv.Atoi(n)
+				y.FPSDen, _ = strconv.Atoi(d)
+			}
+		case 'C':
+			switch {
+			case val == "444" || val == "444p10" || val == "444p12":
+				y.Chroma444 = true
+			case strings.HasPrefix(val, "420"):
+			default:
+				return nil, fmt.Errorf("未対応の Y4M 色空間: %s", val)
+			}
+			if strings.HasSuffix(val, "p10") {
+				y.BitDepth = 10
+			} else if strings.HasSuffix(val, "p12") {
+				y.BitDepth = 12
+			}
+		}
+	}
+	if y.Width <= 0 || y.Height <= 0 || y.FPSNum <= 0 || y.FPSDen <= 0 {
+		return nil, fmt.Errorf("不正な Y4M ヘッダ: %q", strings.TrimSpace(line))
+	}
+	return y, nil
+}
+
+// FPS returns the frame rate declared in the header.
+func (y *Y4MReader) FPS() float64 {
+	return float64(y.FPSNum) / float64(y.FPSDen)
+}
+
+// ReadFrame returns the next 8-bit 4:2:0 frame, or io.EOF at the end of the
+// stream.
+func (y *Y4MReader) ReadFrame() (*I420Frame, error) {
+	if y.BitDepth != 8 || y.Chroma444 {
+		return nil, fmt.Errorf("8 ビット 4:2:0 以外の Y4M は ReadFrame16 で読み込んでください")
+	}
+	if err := y.readFrameHeader(); err != nil {
+		return nil, err
+	}
+
+	f := NewI420Frame(y.Width, y.Height)
+	for _, plane := range [][]byte{f.Y, f.U, f.V} {
+		if _, err := io.ReadFull(y.r, plane); err != nil {
+			return nil, fmt.Errorf("Y4M フレームデータ読み込みエラー: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// ReadFrame16 returns the next frame in its native bit depth and chroma
+// format, or io.EOF at the end of the stream.
+func (y *Y4MReader) ReadFrame16() (*Frame16, error) {
+	if err := y.readFrameHeader(); err != nil {
+		return nil, err
+	}
+
+	f := NewFrame16(y.Width, y.Height, y.BitDepth, y.Chroma444)
+	bps := 1
+	if y.BitDepth > 8 {
+		bps = 2 // 10/12 ビットはリトルエンディアンの 16 ビット
+	}
+	for _, plane := range [][]uint16{f.Y, f.U, f.V} {
+		buf := make([]byte, len(plane)*bps)
+		if _, err := io.ReadFull(y.r, buf); err != nil {
+			return nil, fmt.Errorf("Y4M フレームデータ読み込みエラー: %v", err)
+		}
+		for i := range plane {
+			if bps == 2 {
+				plane[i] = uint16(buf[2*i]) | uint16(buf[2*i+1])<<8
+			} else {
+				plane[i] = uint16(buf[i])
+			}
+		}
+	}
+	return f, nil
+}
+
+func (y *Y4MReader) readFrameHeader() error {
+	line, err := y.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line == "" {
+			return io.EOF
+		}
+		return fmt.Errorf("Y4M フレームヘッダ読み込みエラー: %v", err)
+	}
+	if !strings.HasPrefix(line, "FRAME") {
+		return fmt.Errorf("Y4M フレームヘッダが不正です: %q", strings.TrimSpace(line))
+	}
+	return nil
+}